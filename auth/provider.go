@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ParkerData/parkbench/config"
+)
+
+// TokenProvider returns the bearer token to send with a request, "" for none
+type TokenProvider interface {
+	Token(ctx context.Context) (string, error)
+	// Invalidate drops a token the server rejected so the next call fetches a
+	// new one, unless another request already replaced it
+	Invalidate(token string)
+}
+
+// NewProvider creates the token provider configured in cfg; the jwt option
+// alone is a static token
+func NewProvider(cfg *config.Config) TokenProvider {
+	settings := cfg.Auth
+	if settings == nil {
+		return Static(cfg.JWTString)
+	}
+
+	switch settings.Type {
+	case config.AuthFile:
+		return NewFile(settings.File)
+	case config.AuthExec:
+		return newRefreshing(execSource(settings.Command, settings.TTL.Duration), settings.RefreshBefore.Duration)
+	case config.AuthOAuth2:
+		return newRefreshing(clientCredentialsSource(settings), settings.RefreshBefore.Duration)
+	}
+	return Static(settings.Token)
+}
+
+// Static is a fixed token
+type Static string
+
+// Token returns the fixed token
+func (s Static) Token(context.Context) (string, error) {
+	return string(s), nil
+}
+
+// Invalidate does nothing since a static token cannot change
+func (Static) Invalidate(string) {}
+
+// source fetches a new token and returns when it expires, zero when unknown
+type source func(ctx context.Context) (string, time.Time, error)
+
+const (
+	// fetchTimeout bounds a token fetch so a hung command or token endpoint
+	// cannot stall the workers
+	fetchTimeout = 30 * time.Second
+	// refreshRetry is the wait before refreshing again after a failed refresh
+	refreshRetry = 5 * time.Second
+)
+
+// refreshing caches the token of a source and fetches a new one refreshBefore
+// its expiry, so requests do not start failing when it runs out
+type refreshing struct {
+	mu            sync.Mutex
+	fetch         source
+	refreshBefore time.Duration
+	token         string
+	expiry        time.Time
+	// refreshAt is when the cached token is due to be replaced
+	refreshAt time.Time
+	// refreshing is set while a background refresh runs
+	refreshing bool
+}
+
+func newRefreshing(fetch source, refreshBefore time.Duration) *refreshing {
+	return &refreshing{fetch: fetch, refreshBefore: refreshBefore}
+}
+
+// Token returns the cached token. A token due to be replaced is refreshed in
+// the background while it is still sent; only without a valid token do the
+// workers wait, for a single fetch
+func (r *refreshing) Token(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if r.token != "" && (r.expiry.IsZero() || now.Before(r.refreshAt)) {
+		return r.token, nil
+	}
+	if r.token != "" && now.Before(r.expiry) {
+		if !r.refreshing {
+			r.refreshing = true
+			go r.refresh()
+		}
+		return r.token, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	token, expiry, err := r.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	r.store(token, expiry, time.Now())
+	return token, nil
+}
+
+// refresh replaces the cached token, keeping it when the fetch fails
+func (r *refreshing) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	token, expiry, err := r.fetch(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refreshing = false
+	if err != nil {
+		log.Printf("Failed to refresh token, keeping the current one until %v: %v", r.expiry.Format(time.RFC3339), err)
+		// Try again later, but never send the token past its expiry
+		r.refreshAt = time.Now().Add(refreshRetry)
+		if r.refreshAt.After(r.expiry) {
+			r.refreshAt = r.expiry
+		}
+		return
+	}
+	r.store(token, expiry, time.Now())
+}
+
+// store caches a token fetched at now, taking its expiry from the JWT exp
+// claim when it has one
+func (r *refreshing) store(token string, expiry, now time.Time) {
+	if exp, ok := jwtExpiry(token); ok {
+		expiry = exp
+	}
+	r.token, r.expiry = token, expiry
+	r.refreshAt = refreshTime(expiry, now, r.refreshBefore)
+}
+
+// refreshTime returns when to replace a token fetched at now: refreshBefore
+// its expiry, but no earlier than halfway through its life, or a token living
+// no longer than refreshBefore would be fetched again for every request
+func refreshTime(expiry, now time.Time, refreshBefore time.Duration) time.Time {
+	return expiry.Add(-min(refreshBefore, max(expiry.Sub(now), 0)/2))
+}
+
+// Invalidate drops the cached token if it is the rejected one
+func (r *refreshing) Invalidate(token string) {
+	r.mu.Lock()
+	if r.token == token {
+		r.token = ""
+	}
+	r.mu.Unlock()
+}
+
+// jwtExpiry reads the exp claim of a JWT without verifying it
+func jwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRefreshTime(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name          string
+		lifetime      time.Duration
+		refreshBefore time.Duration
+		want          time.Duration
+	}{
+		{name: "long-lived", lifetime: time.Hour, refreshBefore: time.Minute, want: time.Minute},
+		{name: "lifetime of refreshBefore", lifetime: time.Minute, refreshBefore: time.Minute, want: 30 * time.Second},
+		{name: "shorter than refreshBefore", lifetime: 10 * time.Second, refreshBefore: time.Minute, want: 5 * time.Second},
+		{name: "no refreshBefore", lifetime: time.Hour, want: 0},
+		{name: "already expired", lifetime: -time.Second, refreshBefore: time.Minute, want: 0},
+	}
+	for _, tt := range tests {
+		expiry := now.Add(tt.lifetime)
+		if got := expiry.Sub(refreshTime(expiry, now, tt.refreshBefore)); got != tt.want {
+			t.Errorf("%s: refreshed %v before expiry, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// counter is a token source numbering the tokens it fetches, each valid for lifetime
+type counter struct {
+	mu       sync.Mutex
+	fetches  int
+	lifetime time.Duration
+	// block, when set, holds every fetch after the first until it is closed
+	block chan struct{}
+	// fail makes every fetch after the first fail
+	fail bool
+}
+
+func (c *counter) fetch(ctx context.Context) (string, time.Time, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		return "", time.Time{}, errors.New("fetch without a deadline")
+	}
+	c.mu.Lock()
+	c.fetches++
+	n := c.fetches
+	c.mu.Unlock()
+	if n > 1 && c.block != nil {
+		<-c.block
+	}
+	if n > 1 && c.fail {
+		return "", time.Time{}, errors.New("token endpoint down")
+	}
+	return fmt.Sprintf("token-%d", n), time.Now().Add(c.lifetime), nil
+}
+
+func (c *counter) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.fetches
+}
+
+// token calls Token and fails the test on an error
+func token(t *testing.T, r *refreshing) string {
+	t.Helper()
+	token, err := r.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	return token
+}
+
+func TestRefreshingShortLivedToken(t *testing.T) {
+	c := &counter{lifetime: time.Minute}
+	r := newRefreshing(c.fetch, time.Minute)
+	for range 100 {
+		if got := token(t, r); got != "token-1" {
+			t.Fatalf("Token() = %q, want token-1", got)
+		}
+	}
+	if c.count() != 1 {
+		t.Errorf("%d fetches, want 1", c.count())
+	}
+}
+
+func TestRefreshingInvalidate(t *testing.T) {
+	c := &counter{lifetime: time.Hour}
+	r := newRefreshing(c.fetch, time.Minute)
+	token(t, r)
+
+	// A request rejected with an older token must not drop the current one
+	r.Invalidate("token-0")
+	if got := token(t, r); got != "token-1" || c.count() != 1 {
+		t.Errorf("after invalidating another token: Token() = %q after %d fetches, want token-1 after 1", got, c.count())
+	}
+
+	r.Invalidate("token-1")
+	if got := token(t, r); got != "token-2" {
+		t.Errorf("after invalidating the current token: Token() = %q, want token-2", got)
+	}
+}
+
+func TestRefreshingInBackground(t *testing.T) {
+	c := &counter{lifetime: 200 * time.Millisecond, block: make(chan struct{})}
+	r := newRefreshing(c.fetch, time.Minute)
+	token(t, r)
+
+	// Past the refresh time the current token is still returned at once
+	// while the refresh waits for the token endpoint
+	time.Sleep(120 * time.Millisecond)
+	for range 10 {
+		if got := token(t, r); got != "token-1" {
+			t.Fatalf("Token() during refresh = %q, want token-1", got)
+		}
+	}
+	close(c.block)
+
+	deadline := time.Now().Add(time.Second)
+	for token(t, r) != "token-2" {
+		if time.Now().After(deadline) {
+			t.Fatal("refreshed token never returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if c.count() != 2 {
+		t.Errorf("%d fetches, want 2", c.count())
+	}
+}
+
+func TestRefreshingFailedRefresh(t *testing.T) {
+	c := &counter{lifetime: 200 * time.Millisecond, fail: true}
+	r := newRefreshing(c.fetch, time.Minute)
+	token(t, r)
+
+	time.Sleep(120 * time.Millisecond)
+	if got := token(t, r); got != "token-1" {
+		t.Fatalf("Token() = %q, want token-1", got)
+	}
+	deadline := time.Now().Add(time.Second)
+	for c.count() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("token never refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The failure is not retried for every request while the token is valid
+	for range 10 {
+		if got := token(t, r); got != "token-1" {
+			t.Fatalf("Token() after failed refresh = %q, want token-1", got)
+		}
+	}
+	if c.count() != 2 {
+		t.Errorf("%d fetches, want 2", c.count())
+	}
+
+	// Once expired, requests wait for a fetch and get its error
+	time.Sleep(100 * time.Millisecond)
+	if _, err := r.Token(context.Background()); err == nil {
+		t.Error("Token() of an expired token with a failing source succeeded")
+	}
+}
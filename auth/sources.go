@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ParkerData/parkbench/config"
+)
+
+// fileCheckInterval bounds how often a token file is checked for changes
+const fileCheckInterval = time.Second
+
+// File reads the token from a file and reads it again whenever the file
+// changes, e.g. when a sidecar rotates it
+type File struct {
+	mu      sync.Mutex
+	path    string
+	token   string
+	modTime time.Time
+	checked time.Time
+}
+
+// NewFile creates a provider reading the token from path
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// Token returns the file content without surrounding whitespace
+func (f *File) Token(context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if f.token != "" && now.Sub(f.checked) < fileCheckInterval {
+		return f.token, nil
+	}
+	f.checked = now
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", err
+	}
+	if f.token != "" && info.ModTime().Equal(f.modTime) {
+		return f.token, nil
+	}
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", f.path)
+	}
+	f.token, f.modTime = token, info.ModTime()
+	return f.token, nil
+}
+
+// Invalidate makes the next call read the file again if the rejected token
+// is still the one read last
+func (f *File) Invalidate(token string) {
+	f.mu.Lock()
+	if f.token == token {
+		f.token = ""
+	}
+	f.mu.Unlock()
+}
+
+// execSource runs command and takes its standard output as the token, valid
+// for ttl unless it is a JWT with an exp claim
+func execSource(command []string, ttl time.Duration) source {
+	return func(ctx context.Context) (string, time.Time, error) {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("token command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		token := strings.TrimSpace(string(out))
+		if token == "" {
+			return "", time.Time{}, fmt.Errorf("token command printed no token")
+		}
+		return token, time.Now().Add(ttl), nil
+	}
+}
+
+// clientCredentialsSource fetches access tokens with the OAuth2 client
+// credentials grant (RFC 6749 section 4.4)
+func clientCredentialsSource(settings *config.Auth) source {
+	client := &http.Client{Timeout: 30 * time.Second}
+	return func(ctx context.Context) (string, time.Time, error) {
+		form := url.Values{"grant_type": {"client_credentials"}}
+		if len(settings.Scopes) > 0 {
+			form.Set("scope", strings.Join(settings.Scopes, " "))
+		}
+		if settings.Audience != "" {
+			form.Set("audience", settings.Audience)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, settings.TokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		req.SetBasicAuth(url.QueryEscape(settings.ClientID), url.QueryEscape(settings.ClientSecret))
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("failed to request token: %w", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("failed to read token response: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return "", time.Time{}, fmt.Errorf("token endpoint returned %s: %s", resp.Status, bytes.TrimSpace(body))
+		}
+
+		var token struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int64  `json:"expires_in"`
+		}
+		if err := json.Unmarshal(body, &token); err != nil {
+			return "", time.Time{}, fmt.Errorf("invalid token response: %w", err)
+		}
+		if token.AccessToken == "" {
+			return "", time.Time{}, fmt.Errorf("token response has no access_token")
+		}
+		var expiry time.Time
+		if token.ExpiresIn > 0 {
+			expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+		}
+		return token.AccessToken, expiry, nil
+	}
+}
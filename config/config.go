@@ -1,8 +1,83 @@
 package config
 
 import (
+	"crypto/tls"
 	"encoding/json"
+	"fmt"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Arrival distributions for open-loop load
+const (
+	ArrivalConstant = "constant"
+	ArrivalPoisson  = "poisson"
+)
+
+// Orders in which the input rows are sent
+const (
+	ShuffleFull      = "full"
+	ShuffleWindow    = "window"
+	ShuffleReservoir = "reservoir"
+	ShuffleNone      = "none"
+)
+
+// Key access distributions
+const (
+	DistributionShuffle    = "shuffle"
+	DistributionUniform    = "uniform"
+	DistributionZipfian    = "zipfian"
+	DistributionHotspot    = "hotspot"
+	DistributionSequential = "sequential"
+	DistributionLatest     = "latest"
+)
+
+// Synthetic key generators
+const (
+	GenerateRange    = "range"
+	GenerateTemplate = "template"
+	GenerateUUID     = "uuid"
+	GenerateBytes    = "bytes"
+)
+
+// Key types and the encodings accepted for bytes keys
+const (
+	KeyString = "string"
+	KeyInt32  = "int32"
+	KeyInt64  = "int64"
+	KeyBytes  = "bytes"
+
+	EncodingHex    = "hex"
+	EncodingBase64 = "base64"
+)
+
+// Roles of the CSV columns listed in CSVColumns; an empty name skips the column.
+// The key column may declare its type as "key:<type>" or "key:bytes:<encoding>".
+// "expect:<name>" or "expect:<name>:<type>" holds the value the record column
+// name must have in the response
+const (
+	ColumnKey             = "key"
+	ColumnKeyColumn       = "keyColumn"
+	ColumnSnapshot        = "snapshot"
+	ColumnColumns         = "columns"
+	ColumnPartitionPrefix = "partition:"
+	ColumnExpectPrefix    = "expect:"
+)
+
+// Types of the expected record values; bytes are written in hex
+const (
+	ValueString = "string"
+	ValueBool   = "bool"
+	ValueInt32  = "int32"
+	ValueInt64  = "int64"
+	ValueFloat  = "float"
+	ValueDouble = "double"
+	ValueBytes  = "bytes"
 )
 
 // Config represents the benchmark configuration
@@ -13,8 +88,329 @@ type Config struct {
 	Concurrency       int    `json:"concurrency"`
 	RepeatTimes       int    `json:"repeat"`
 	JWTString         string `json:"jwt"`
+	Plaintext         bool   `json:"plaintext"`
 	AccountName       string `json:"account"`
 	TableName         string `json:"table"`
+
+	// Rate is the target requests per second; when set the benchmark runs
+	// open-loop and Concurrency caps the number of requests in flight
+	Rate    float64 `json:"rate"`
+	Arrival string  `json:"arrival"`
+
+	// MaxErrors and MaxErrorRate abort the run once exceeded; zero means unlimited
+	MaxErrors    int64   `json:"maxErrors"`
+	MaxErrorRate float64 `json:"maxErrorRate"`
+
+	// RequestTimeout bounds every request from the moment it is sent;
+	// RecordTimeouts adds timed out requests to the latencies so they show in
+	// the tail instead of only being counted
+	RequestTimeout Duration `json:"requestTimeout"`
+	RecordTimeouts bool     `json:"recordTimeouts"`
+
+	// Duration switches from RepeatTimes to a timed run; samples taken during
+	// Warmup and Cooldown are shown live but excluded from the results
+	Duration Duration `json:"duration"`
+	Warmup   Duration `json:"warmup"`
+	Cooldown Duration `json:"cooldown"`
+
+	// Optional FindRequest fields sent with every request
+	Partitions map[string]string `json:"partitions"`
+	KeyColumn  string            `json:"keyColumn"`
+	Snapshot   int64             `json:"snapshot"`
+	Columns    []string          `json:"columns"`
+
+	// CSVColumns names the role of each CSV column so rows can override the
+	// FindRequest fields above, e.g. ["key", "partition:region", "snapshot"]
+	CSVColumns []string `json:"csvColumns"`
+
+	// KeyType selects the Key variant the IDs are parsed into and KeyEncoding
+	// how bytes keys are written in the input
+	KeyType     string `json:"keyType"`
+	KeyEncoding string `json:"keyEncoding"`
+
+	// CSVHeader skips the first CSV row; CSVKeyColumn picks the key column by
+	// header name or zero-based index as an alternative to CSVColumns
+	CSVHeader    bool   `json:"csvHeader"`
+	CSVKeyColumn string `json:"csvKeyColumn"`
+
+	// Shuffle selects the order rows are sent in: "full" shuffles the whole
+	// file in memory, "window" streams it through a ShuffleWindow row buffer,
+	// "reservoir" keeps a random sample of SampleSize rows and "none" streams
+	// the file as is
+	Shuffle       string `json:"shuffle"`
+	ShuffleWindow int    `json:"shuffleWindow"`
+	SampleSize    int    `json:"sampleSize"`
+
+	// Distribution selects how keys are picked: "shuffle" passes over the
+	// input in the Shuffle order, the others pick from the rows loaded by the
+	// full or reservoir shuffle, "repeat" times as many requests as rows
+	Distribution   string  `json:"distribution"`
+	ZipfTheta      float64 `json:"zipfTheta"`
+	HotspotKeys    float64 `json:"hotspotKeys"`
+	HotspotTraffic float64 `json:"hotspotTraffic"`
+
+	// Seed makes the key order reproducible; a random seed is picked and
+	// recorded here when unset
+	Seed uint64 `json:"seed"`
+
+	// Generate replaces the CSV file with synthetic keys
+	Generate *Generate `json:"generate"`
+
+	// MissFraction of the requests look up keys expected to be missing, read
+	// from the first column of MissCSV or generated when it is unset
+	MissFraction float64 `json:"missFraction"`
+	MissCSV      string  `json:"missCsv"`
+
+	// Tracing exports a span per request when set
+	Tracing *Tracing `json:"tracing"`
+
+	// GRPC tunes the gRPC channels
+	GRPC *GRPCChannel `json:"grpc"`
+
+	// TLS configures the TLS connections to both servers; Plaintext turns TLS
+	// off instead
+	TLS *TLS `json:"tls"`
+
+	// HTTPVersion selects the HTTP protocol; HTTPConnections caps the
+	// connections of HTTP/1.1, 0 for no limit
+	HTTPVersion     string `json:"httpVersion"`
+	HTTPConnections int    `json:"httpConnections"`
+
+	// Auth selects where the bearer token comes from instead of the static JWTString
+	Auth *Auth `json:"auth"`
+
+	// Retry re-sends requests that failed with a retryable error when set
+	Retry *Retry `json:"retry"`
+
+	// Hedge sends a duplicate of requests that have not returned in time
+	Hedge *Hedge `json:"hedge"`
+
+	// Endpoints replaces the single httpAddress and grpcAddress with servers
+	// every send is balanced across by the Balance policy
+	Endpoints []Endpoint `json:"endpoints"`
+	Balance   string     `json:"balance"`
+
+	// Targets runs the same workload against each target in turn, or all at
+	// once with ConcurrentTargets, and compares the results
+	Targets           []Target `json:"targets"`
+	ConcurrentTargets bool     `json:"concurrentTargets"`
+
+	// raw is the configuration file the targets are laid over
+	raw []byte
+}
+
+// Target names a set of settings, e.g. the server addresses of a region,
+// that override the rest of the configuration for its run
+type Target struct {
+	Name     string
+	settings json.RawMessage
+}
+
+// UnmarshalJSON keeps the settings of the target to lay them over the configuration
+func (target *Target) UnmarshalJSON(data []byte) error {
+	var named struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &named); err != nil {
+		return err
+	}
+	target.Name = named.Name
+	target.settings = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// MarshalJSON writes the target as it was configured
+func (target Target) MarshalJSON() ([]byte, error) {
+	return target.settings, nil
+}
+
+// Load balancing policies across Endpoints; least_outstanding picks the
+// endpoint with the fewest requests in flight and weighted picks at random
+// in proportion to Weight
+const (
+	BalanceRoundRobin       = "round_robin"
+	BalanceRandom           = "random"
+	BalanceLeastOutstanding = "least_outstanding"
+	BalanceWeighted         = "weighted"
+)
+
+// Endpoint is one server of a fleet, named in the per-endpoint results by
+// Name or its address. Resolve looks the host up once at start and turns
+// every address it resolves to into an endpoint of its own, named Name/IP
+type Endpoint struct {
+	Name        string `json:"name"`
+	HTTPAddress string `json:"httpAddress"`
+	GRPCAddress string `json:"grpcAddress"`
+	Weight      int    `json:"weight"`
+	Resolve     bool   `json:"resolve"`
+}
+
+// Hedge sends a second copy of a request still waiting for its reply after
+// Delay, or after the live Percentile of the reply latencies once enough are
+// known, to HTTPAddress or GRPCAddress when set or the same server otherwise;
+// the first reply wins and the other copy is cancelled. Control is the
+// fraction of requests sent without hedging to compare against
+type Hedge struct {
+	Delay       Duration `json:"delay"`
+	Percentile  float64  `json:"percentile"`
+	HTTPAddress string   `json:"httpAddress"`
+	GRPCAddress string   `json:"grpcAddress"`
+	Control     float64  `json:"control"`
+}
+
+// Retry is the retry policy of a request: up to MaxAttempts sends in total,
+// retrying the error classes in RetryOn after an exponential backoff from
+// InitialBackoff growing by Multiplier up to MaxBackoff, randomized by
+// +/- Jitter of itself
+type Retry struct {
+	MaxAttempts    int      `json:"maxAttempts"`
+	RetryOn        []string `json:"retryOn"`
+	InitialBackoff Duration `json:"initialBackoff"`
+	MaxBackoff     Duration `json:"maxBackoff"`
+	Multiplier     float64  `json:"multiplier"`
+	Jitter         float64  `json:"jitter"`
+}
+
+// Token providers
+const (
+	AuthStatic = "static"
+	AuthFile   = "file"
+	AuthExec   = "exec"
+	AuthOAuth2 = "oauth2"
+)
+
+// Auth configures the bearer token: a static Token, the content of File,
+// the output of Command or an OAuth2 client credentials grant against
+// TokenURL. Tokens are refreshed RefreshBefore their JWT exp or expires_in;
+// command output without exp is kept for TTL
+type Auth struct {
+	Type          string   `json:"type"`
+	Token         string   `json:"token"`
+	File          string   `json:"file"`
+	Command       []string `json:"command"`
+	TTL           Duration `json:"ttl"`
+	TokenURL      string   `json:"tokenUrl"`
+	ClientID      string   `json:"clientId"`
+	ClientSecret  string   `json:"clientSecret"`
+	Scopes        []string `json:"scopes"`
+	Audience      string   `json:"audience"`
+	RefreshBefore Duration `json:"refreshBefore"`
+}
+
+// HTTP versions: "auto" lets TLS negotiate HTTP/2 or HTTP/1.1, "http1" and
+// "http2" force one over TLS or cleartext for http1, and "h2c" sends HTTP/2
+// over cleartext with prior knowledge
+const (
+	HTTPVersionAuto = "auto"
+	HTTPVersion1    = "http1"
+	HTTPVersion2    = "http2"
+	HTTPVersionH2C  = "h2c"
+)
+
+// TLS holds the client TLS settings. CAFile replaces the system CAs, CertFile
+// and KeyFile authenticate the client for mTLS, MinVersion is one of "1.0" to
+// "1.3" and CipherSuites restricts the TLS 1.2 and older suites by name
+type TLS struct {
+	CAFile             string   `json:"caFile"`
+	CertFile           string   `json:"certFile"`
+	KeyFile            string   `json:"keyFile"`
+	ServerName         string   `json:"serverName"`
+	MinVersion         string   `json:"minVersion"`
+	CipherSuites       []string `json:"cipherSuites"`
+	InsecureSkipVerify bool     `json:"insecureSkipVerify"`
+}
+
+// TLSVersions maps the MinVersion names to their crypto/tls values
+var TLSVersions = map[string]uint16{
+	"":    0,
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// CipherSuite returns the crypto/tls ID of a cipher suite name such as
+// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"
+func CipherSuite(name string) (uint16, bool) {
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
+
+// Load-balancing policies of a gRPC channel across the resolved addresses
+const (
+	LoadBalancingPickFirst  = "pick_first"
+	LoadBalancingRoundRobin = "round_robin"
+)
+
+// GRPCChannel configures the gRPC connections. Connections are shared
+// round-robin by all workers, each worker dialing its own when 0; sizes are
+// in bytes and zero values keep the gRPC defaults
+type GRPCChannel struct {
+	Connections                  int      `json:"connections"`
+	KeepaliveTime                Duration `json:"keepaliveTime"`
+	KeepaliveTimeout             Duration `json:"keepaliveTimeout"`
+	KeepalivePermitWithoutStream bool     `json:"keepalivePermitWithoutStream"`
+	MaxRecvMessageSize           int      `json:"maxRecvMessageSize"`
+	MaxSendMessageSize           int      `json:"maxSendMessageSize"`
+	InitialWindowSize            int32    `json:"initialWindowSize"`
+	InitialConnWindowSize        int32    `json:"initialConnWindowSize"`
+	LoadBalancing                string   `json:"loadBalancing"`
+}
+
+// Exporters of the request spans
+const (
+	ExporterOTLP = "otlp"
+	ExporterFile = "file"
+)
+
+// Tracing describes where request spans go: an OTLP/gRPC collector at
+// Endpoint, or JSON lines in File. SampleRate is the fraction of requests traced
+type Tracing struct {
+	Exporter    string  `json:"exporter"`
+	Endpoint    string  `json:"endpoint"`
+	Insecure    bool    `json:"insecure"`
+	File        string  `json:"file"`
+	SampleRate  float64 `json:"sampleRate"`
+	ServiceName string  `json:"serviceName"`
+}
+
+// Generate describes a list of Count synthetic keys: the integers from Start,
+// the same integers formatted with Template, UUIDs or random keys of Length
+// bytes, the last two derived from the seed
+type Generate struct {
+	Type     string `json:"type"`
+	Count    int    `json:"count"`
+	Start    int64  `json:"start"`
+	Template string `json:"template"`
+	Length   int    `json:"length"`
+}
+
+// Duration is a time.Duration read from a JSON string such as "30s" or "5m"
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses the duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %w", err)
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = duration
+	return nil
+}
+
+// MarshalJSON formats the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
 }
 
 // LoadConfig loads the configuration from a JSON file
@@ -31,6 +427,543 @@ func LoadConfig(configPath string) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	if config.raw, err = os.ReadFile(configPath); err != nil {
+		return nil, err
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	for _, target := range config.Targets {
+		if _, err := config.ForTarget(target); err != nil {
+			return nil, err
+		}
+	}
 
 	return config, nil
 }
+
+// ForTarget returns the configuration of the run against target: the
+// configuration file with the settings of the target laid over it. The seed
+// is shared so every target is sent the same keys in the same order
+func (config *Config) ForTarget(target Target) (*Config, error) {
+	merged := &Config{}
+	if err := json.Unmarshal(config.raw, merged); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(target.settings, merged); err != nil {
+		return nil, fmt.Errorf("target %s: %w", target.Name, err)
+	}
+	merged.Targets = nil
+	merged.ConcurrentTargets = false
+	if merged.Seed == 0 {
+		merged.Seed = config.Seed
+	}
+	if err := merged.validate(); err != nil {
+		return nil, fmt.Errorf("target %s: %w", target.Name, err)
+	}
+	return merged, nil
+}
+
+// KeyIndex returns the position of the key in a list of CSV column roles
+func KeyIndex(columns []string) int {
+	return slices.IndexFunc(columns, isKeyColumn)
+}
+
+func isKeyColumn(column string) bool {
+	return column == ColumnKey || strings.HasPrefix(column, ColumnKey+":")
+}
+
+// ExpectColumn returns the record column and value type an "expect:" CSV
+// column checks, the type defaulting to string
+func ExpectColumn(column string) (name string, valueType string, ok bool) {
+	spec, ok := strings.CutPrefix(column, ColumnExpectPrefix)
+	if !ok {
+		return "", "", false
+	}
+	name, valueType, _ = strings.Cut(spec, ":")
+	if valueType == "" {
+		valueType = ValueString
+	}
+	return name, valueType, true
+}
+
+// Redacted returns a copy of the configuration that is safe to publish
+func (config *Config) Redacted() Config {
+	redacted := *config
+	if redacted.JWTString != "" {
+		redacted.JWTString = "REDACTED"
+	}
+	if redacted.Auth != nil {
+		auth := *redacted.Auth
+		if auth.Token != "" {
+			auth.Token = "REDACTED"
+		}
+		if auth.ClientSecret != "" {
+			auth.ClientSecret = "REDACTED"
+		}
+		redacted.Auth = &auth
+	}
+	return redacted
+}
+
+// validate fills in defaults and rejects inconsistent settings
+func (config *Config) validate() error {
+	if config.Arrival == "" {
+		config.Arrival = ArrivalConstant
+	}
+	if config.Arrival != ArrivalConstant && config.Arrival != ArrivalPoisson {
+		return fmt.Errorf("unknown arrival distribution %q", config.Arrival)
+	}
+	if config.Rate < 0 {
+		return fmt.Errorf("rate must not be negative")
+	}
+	if config.MaxErrorRate < 0 || config.MaxErrorRate > 1 {
+		return fmt.Errorf("maxErrorRate must be between 0 and 1")
+	}
+	if config.Duration.Duration < 0 || config.Warmup.Duration < 0 || config.Cooldown.Duration < 0 {
+		return fmt.Errorf("duration, warmup and cooldown must not be negative")
+	}
+	if config.Cooldown.Duration > 0 && config.Duration.Duration == 0 {
+		return fmt.Errorf("cooldown requires duration")
+	}
+	if config.RequestTimeout.Duration < 0 {
+		return fmt.Errorf("requestTimeout must not be negative")
+	}
+	if config.RequestTimeout.Duration == 0 {
+		config.RequestTimeout.Duration = 120 * time.Second
+	}
+
+	if config.Generate != nil {
+		if err := config.Generate.validate(); err != nil {
+			return err
+		}
+		if config.CSVFilePath != "" {
+			return fmt.Errorf("csv and generate are mutually exclusive")
+		}
+		if len(config.CSVColumns) > 1 || config.CSVKeyColumn != "" || config.CSVHeader {
+			return fmt.Errorf("csv layout settings do not apply to generated keys")
+		}
+		if config.Generate.Type == GenerateBytes && config.KeyType == "" {
+			config.KeyType = KeyBytes
+		}
+	}
+	if config.CSVKeyColumn != "" && len(config.CSVColumns) > 0 {
+		return fmt.Errorf("csvKeyColumn and csvColumns are mutually exclusive")
+	}
+	if len(config.CSVColumns) == 0 {
+		config.CSVColumns = []string{ColumnKey}
+	}
+	keys := 0
+	for _, column := range config.CSVColumns {
+		if isKeyColumn(column) {
+			keys++
+			// A type declared on the key column takes precedence over keyType
+			if parts := strings.Split(column, ":"); len(parts) > 1 {
+				config.KeyType = parts[1]
+				if len(parts) > 2 {
+					config.KeyEncoding = parts[2]
+				}
+			}
+		}
+		switch {
+		case column == "", isKeyColumn(column), column == ColumnKeyColumn, column == ColumnSnapshot, column == ColumnColumns:
+		case strings.HasPrefix(column, ColumnPartitionPrefix) && column != ColumnPartitionPrefix:
+		case strings.HasPrefix(column, ColumnExpectPrefix):
+			name, valueType, _ := ExpectColumn(column)
+			if name == "" {
+				return fmt.Errorf("csvColumns entry %q names no record column", column)
+			}
+			if !slices.Contains([]string{ValueString, ValueBool, ValueInt32, ValueInt64, ValueFloat, ValueDouble, ValueBytes}, valueType) {
+				return fmt.Errorf("unknown value type %q in csvColumns entry %q", valueType, column)
+			}
+		default:
+			return fmt.Errorf("unknown csvColumns entry %q", column)
+		}
+	}
+	if keys != 1 {
+		return fmt.Errorf("csvColumns must contain %q exactly once", ColumnKey)
+	}
+
+	if config.KeyType == "" {
+		config.KeyType = KeyString
+	}
+	if !slices.Contains([]string{KeyString, KeyInt32, KeyInt64, KeyBytes}, config.KeyType) {
+		return fmt.Errorf("unknown key type %q", config.KeyType)
+	}
+	if config.KeyEncoding == "" && config.KeyType == KeyBytes {
+		config.KeyEncoding = EncodingHex
+	}
+	if config.KeyEncoding != "" && config.KeyEncoding != EncodingHex && config.KeyEncoding != EncodingBase64 {
+		return fmt.Errorf("unknown key encoding %q", config.KeyEncoding)
+	}
+
+	if config.Shuffle == "" {
+		config.Shuffle = ShuffleFull
+	}
+	switch config.Shuffle {
+	case ShuffleFull, ShuffleNone:
+	case ShuffleWindow:
+		if config.ShuffleWindow == 0 {
+			config.ShuffleWindow = 100000
+		}
+		if config.ShuffleWindow < 0 {
+			return fmt.Errorf("shuffleWindow must be positive")
+		}
+	case ShuffleReservoir:
+		if config.SampleSize <= 0 {
+			return fmt.Errorf("reservoir shuffle requires a positive sampleSize")
+		}
+	default:
+		return fmt.Errorf("unknown shuffle %q", config.Shuffle)
+	}
+
+	if config.Distribution == "" {
+		config.Distribution = DistributionShuffle
+	}
+	switch config.Distribution {
+	case DistributionShuffle:
+	case DistributionUniform, DistributionZipfian, DistributionHotspot, DistributionSequential, DistributionLatest:
+		if config.Generate == nil && config.Shuffle != ShuffleFull && config.Shuffle != ShuffleReservoir {
+			return fmt.Errorf("distribution %q requires the full or reservoir shuffle", config.Distribution)
+		}
+	default:
+		return fmt.Errorf("unknown distribution %q", config.Distribution)
+	}
+	if config.Distribution == DistributionZipfian || config.Distribution == DistributionLatest {
+		if config.ZipfTheta == 0 {
+			config.ZipfTheta = 0.99
+		}
+		if config.ZipfTheta <= 0 || config.ZipfTheta >= 1 {
+			return fmt.Errorf("zipfTheta must be between 0 and 1")
+		}
+	}
+	if config.Distribution == DistributionHotspot {
+		if config.HotspotKeys == 0 {
+			config.HotspotKeys = 0.2
+		}
+		if config.HotspotTraffic == 0 {
+			config.HotspotTraffic = 0.8
+		}
+		if config.HotspotKeys <= 0 || config.HotspotKeys > 1 || config.HotspotTraffic < 0 || config.HotspotTraffic > 1 {
+			return fmt.Errorf("hotspotKeys and hotspotTraffic must be between 0 and 1")
+		}
+	}
+
+	if config.MissFraction < 0 || config.MissFraction >= 1 {
+		return fmt.Errorf("missFraction must be at least 0 and below 1")
+	}
+	if config.MissCSV != "" && config.MissFraction == 0 {
+		return fmt.Errorf("missCsv requires a positive missFraction")
+	}
+
+	if config.Tracing != nil {
+		if err := config.Tracing.validate(); err != nil {
+			return err
+		}
+	}
+	if config.TLS != nil {
+		if config.Plaintext {
+			return fmt.Errorf("tls and plaintext are mutually exclusive")
+		}
+		if err := config.TLS.validate(); err != nil {
+			return err
+		}
+	}
+	names := make(map[string]bool)
+	for _, target := range config.Targets {
+		if target.Name == "" {
+			return fmt.Errorf("targets require a name")
+		}
+		if names[target.Name] {
+			return fmt.Errorf("target %s is defined twice", target.Name)
+		}
+		names[target.Name] = true
+	}
+	if len(config.Endpoints) > 0 && (config.HTTPServerAddress != "" || config.GRPCServerAddress != "") {
+		return fmt.Errorf("endpoints replace httpAddress and grpcAddress")
+	}
+	for i := range config.Endpoints {
+		if err := config.Endpoints[i].validate(); err != nil {
+			return err
+		}
+	}
+	if config.Balance == "" {
+		config.Balance = BalanceRoundRobin
+	}
+	switch config.Balance {
+	case BalanceRoundRobin, BalanceRandom, BalanceLeastOutstanding, BalanceWeighted:
+	default:
+		return fmt.Errorf("unknown balance policy %q", config.Balance)
+	}
+	if config.HTTPVersion == "" {
+		config.HTTPVersion = HTTPVersionAuto
+	}
+	switch config.HTTPVersion {
+	case HTTPVersionAuto, HTTPVersion1, HTTPVersion2, HTTPVersionH2C:
+	default:
+		return fmt.Errorf("unknown httpVersion %q", config.HTTPVersion)
+	}
+	for _, address := range config.HTTPAddresses() {
+		if config.Plaintext && strings.HasPrefix(address, "https://") {
+			return fmt.Errorf("plaintext requires an http:// httpAddress")
+		}
+		if config.HTTPVersion == HTTPVersion2 && !strings.HasPrefix(address, "https://") {
+			return fmt.Errorf("httpVersion http2 requires an https:// httpAddress, use h2c for cleartext")
+		}
+		if config.HTTPVersion == HTTPVersionH2C && !strings.HasPrefix(address, "http://") {
+			return fmt.Errorf("httpVersion h2c requires an http:// httpAddress")
+		}
+	}
+	if config.HTTPConnections < 0 {
+		return fmt.Errorf("httpConnections must not be negative")
+	}
+	if config.Retry != nil {
+		if err := config.Retry.validate(); err != nil {
+			return err
+		}
+	}
+	if config.Hedge != nil {
+		if err := config.Hedge.validate(); err != nil {
+			return err
+		}
+	}
+	if config.Auth != nil {
+		if config.JWTString != "" {
+			return fmt.Errorf("jwt and auth are mutually exclusive")
+		}
+		if err := config.Auth.validate(); err != nil {
+			return err
+		}
+	}
+	if config.GRPC == nil {
+		config.GRPC = &GRPCChannel{}
+	}
+	if err := config.GRPC.validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (auth *Auth) validate() error {
+	switch auth.Type {
+	case AuthStatic:
+		if auth.Token == "" {
+			return fmt.Errorf("static auth requires a token")
+		}
+	case AuthFile:
+		if auth.File == "" {
+			return fmt.Errorf("file auth requires a file")
+		}
+	case AuthExec:
+		if len(auth.Command) == 0 {
+			return fmt.Errorf("exec auth requires a command")
+		}
+		if auth.TTL.Duration == 0 {
+			auth.TTL.Duration = 5 * time.Minute
+		}
+		if auth.RefreshBefore.Duration >= auth.TTL.Duration {
+			return fmt.Errorf("auth refreshBefore must be shorter than ttl")
+		}
+	case AuthOAuth2:
+		if auth.TokenURL == "" || auth.ClientID == "" {
+			return fmt.Errorf("oauth2 auth requires tokenUrl and clientId")
+		}
+	default:
+		return fmt.Errorf("unknown auth type %q", auth.Type)
+	}
+	if auth.RefreshBefore.Duration == 0 {
+		auth.RefreshBefore.Duration = time.Minute
+	}
+	if auth.TTL.Duration < 0 || auth.RefreshBefore.Duration < 0 {
+		return fmt.Errorf("auth ttl and refreshBefore must not be negative")
+	}
+	return nil
+}
+
+func (retry *Retry) validate() error {
+	if retry.MaxAttempts == 0 {
+		retry.MaxAttempts = 3
+	}
+	if len(retry.RetryOn) == 0 {
+		retry.RetryOn = []string{"http_503", "grpc_Unavailable"}
+	}
+	if retry.InitialBackoff.Duration == 0 {
+		retry.InitialBackoff.Duration = 50 * time.Millisecond
+	}
+	if retry.MaxBackoff.Duration == 0 {
+		retry.MaxBackoff.Duration = time.Second
+	}
+	if retry.Multiplier == 0 {
+		retry.Multiplier = 2
+	}
+	if retry.MaxAttempts < 1 {
+		return fmt.Errorf("retry maxAttempts must be at least 1")
+	}
+	for _, class := range retry.RetryOn {
+		if !retryableClass(class) {
+			return fmt.Errorf("retry cannot retry on %q, use http_<status>, grpc_<code>, timeout or transport", class)
+		}
+	}
+	if retry.InitialBackoff.Duration < 0 || retry.MaxBackoff.Duration < retry.InitialBackoff.Duration {
+		return fmt.Errorf("retry initialBackoff must not be negative or exceed maxBackoff")
+	}
+	if retry.Multiplier < 1 {
+		return fmt.Errorf("retry multiplier must be at least 1")
+	}
+	if retry.Jitter < 0 || retry.Jitter > 1 {
+		return fmt.Errorf("retry jitter must be between 0 and 1")
+	}
+	return nil
+}
+
+// retryableClass reports whether class names an error worth sending again:
+// an HTTP error status, a gRPC code, a timeout or a transport failure
+func retryableClass(class string) bool {
+	switch class {
+	case "timeout", "transport":
+		return true
+	}
+	if status, ok := strings.CutPrefix(class, "http_"); ok {
+		code, err := strconv.Atoi(status)
+		return err == nil && code >= 400 && code <= 599 && code != 404
+	}
+	if name, ok := strings.CutPrefix(class, "grpc_"); ok {
+		for code := codes.Canceled; code <= codes.Unauthenticated; code++ {
+			if code != codes.NotFound && code.String() == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (endpoint *Endpoint) validate() error {
+	if endpoint.HTTPAddress == "" && endpoint.GRPCAddress == "" {
+		return fmt.Errorf("endpoints require an httpAddress or grpcAddress")
+	}
+	if endpoint.Weight < 0 {
+		return fmt.Errorf("endpoint weight must not be negative")
+	}
+	if endpoint.Weight == 0 {
+		endpoint.Weight = 1
+	}
+	return nil
+}
+
+// HTTPAddresses returns every configured HTTP server address
+func (config *Config) HTTPAddresses() []string {
+	var addresses []string
+	if config.HTTPServerAddress != "" {
+		addresses = append(addresses, config.HTTPServerAddress)
+	}
+	for _, endpoint := range config.Endpoints {
+		if endpoint.HTTPAddress != "" {
+			addresses = append(addresses, endpoint.HTTPAddress)
+		}
+	}
+	if config.Hedge != nil && config.Hedge.HTTPAddress != "" {
+		addresses = append(addresses, config.Hedge.HTTPAddress)
+	}
+	return addresses
+}
+
+func (hedge *Hedge) validate() error {
+	if hedge.Delay.Duration < 0 {
+		return fmt.Errorf("hedge delay must not be negative")
+	}
+	if hedge.Percentile < 0 || hedge.Percentile >= 100 {
+		return fmt.Errorf("hedge percentile must be between 0 and 100")
+	}
+	if hedge.Delay.Duration == 0 && hedge.Percentile == 0 {
+		return fmt.Errorf("hedge requires a delay or a percentile")
+	}
+	if hedge.Control < 0 || hedge.Control >= 1 {
+		return fmt.Errorf("hedge control must be at least 0 and less than 1")
+	}
+	return nil
+}
+
+func (settings *TLS) validate() error {
+	if (settings.CertFile == "") != (settings.KeyFile == "") {
+		return fmt.Errorf("tls certFile and keyFile must be set together")
+	}
+	if _, ok := TLSVersions[settings.MinVersion]; !ok {
+		return fmt.Errorf("unknown tls minVersion %q", settings.MinVersion)
+	}
+	for _, name := range settings.CipherSuites {
+		if _, ok := CipherSuite(name); !ok {
+			return fmt.Errorf("unknown tls cipher suite %q", name)
+		}
+	}
+	return nil
+}
+
+func (channel *GRPCChannel) validate() error {
+	if channel.Connections < 0 || channel.MaxRecvMessageSize < 0 || channel.MaxSendMessageSize < 0 {
+		return fmt.Errorf("grpc connections and message sizes must not be negative")
+	}
+	if channel.KeepaliveTime.Duration < 0 || channel.KeepaliveTimeout.Duration < 0 {
+		return fmt.Errorf("grpc keepalive durations must not be negative")
+	}
+	// gRPC ignores window sizes below 64KiB
+	const minWindowSize = 64 * 1024
+	if (channel.InitialWindowSize != 0 && channel.InitialWindowSize < minWindowSize) || (channel.InitialConnWindowSize != 0 && channel.InitialConnWindowSize < minWindowSize) {
+		return fmt.Errorf("grpc window sizes must be at least %d bytes", minWindowSize)
+	}
+	switch channel.LoadBalancing {
+	case "", LoadBalancingPickFirst, LoadBalancingRoundRobin:
+	default:
+		return fmt.Errorf("unknown grpc loadBalancing policy %q", channel.LoadBalancing)
+	}
+	return nil
+}
+
+func (tracing *Tracing) validate() error {
+	if tracing.Exporter == "" {
+		tracing.Exporter = ExporterOTLP
+		if tracing.File != "" {
+			tracing.Exporter = ExporterFile
+		}
+	}
+	switch tracing.Exporter {
+	case ExporterOTLP:
+	case ExporterFile:
+		if tracing.File == "" {
+			return fmt.Errorf("file tracing exporter requires a file")
+		}
+	default:
+		return fmt.Errorf("unknown tracing exporter %q", tracing.Exporter)
+	}
+	if tracing.SampleRate == 0 {
+		tracing.SampleRate = 1
+	}
+	if tracing.SampleRate < 0 || tracing.SampleRate > 1 {
+		return fmt.Errorf("tracing sampleRate must be between 0 and 1")
+	}
+	if tracing.ServiceName == "" {
+		tracing.ServiceName = "parkbench"
+	}
+	return nil
+}
+
+func (generate *Generate) validate() error {
+	if generate.Count <= 0 {
+		return fmt.Errorf("generate requires a positive count")
+	}
+	switch generate.Type {
+	case GenerateRange, GenerateUUID:
+	case GenerateTemplate:
+		if !strings.Contains(generate.Template, "%") {
+			return fmt.Errorf("generate template %q has no formatting verb such as %%08d", generate.Template)
+		}
+	case GenerateBytes:
+		if generate.Length <= 0 {
+			return fmt.Errorf("generate bytes requires a positive length")
+		}
+	default:
+		return fmt.Errorf("unknown generate type %q", generate.Type)
+	}
+	return nil
+}
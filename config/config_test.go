@@ -0,0 +1,145 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadConfig writes content to a configuration file and loads it
+func loadConfig(t *testing.T, content string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return LoadConfig(path)
+}
+
+func TestForTarget(t *testing.T) {
+	const base = `{
+		"httpAddress": "http://gateway.example.com",
+		"grpcAddress": "gateway.example.com:50051",
+		"csv": "keys.csv",
+		"account": "a",
+		"table": "t",
+		"concurrency": 8,
+		"requestTimeout": "2s",
+		"retry": {"maxAttempts": 3, "retryOn": ["http_503"]},
+		"targets": [%s]
+	}`
+	tests := []struct {
+		name   string
+		target string
+		seed   uint64
+		check  func(t *testing.T, cfg *Config)
+	}{
+		{
+			name:   "inherits everything but the name",
+			target: `{"name": "default"}`,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.HTTPServerAddress != "http://gateway.example.com" || cfg.Concurrency != 8 || cfg.RequestTimeout.Duration != 2*time.Second {
+					t.Errorf("settings not inherited: %+v", cfg)
+				}
+			},
+		},
+		{
+			name:   "overrides addresses",
+			target: `{"name": "eu", "httpAddress": "http://eu.example.com", "grpcAddress": "eu.example.com:50051"}`,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.HTTPServerAddress != "http://eu.example.com" || cfg.GRPCServerAddress != "eu.example.com:50051" {
+					t.Errorf("addresses = %q, %q", cfg.HTTPServerAddress, cfg.GRPCServerAddress)
+				}
+				if cfg.Concurrency != 8 {
+					t.Errorf("concurrency = %d, want 8", cfg.Concurrency)
+				}
+			},
+		},
+		{
+			name:   "merges nested settings",
+			target: `{"name": "eu", "retry": {"maxAttempts": 5}}`,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Retry.MaxAttempts != 5 || len(cfg.Retry.RetryOn) != 1 || cfg.Retry.RetryOn[0] != "http_503" {
+					t.Errorf("retry = %+v", cfg.Retry)
+				}
+			},
+		},
+		{
+			name:   "fills in defaults",
+			target: `{"name": "eu", "requestTimeout": "0s"}`,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.RequestTimeout.Duration != 120*time.Second {
+					t.Errorf("requestTimeout = %v, want the 120s default", cfg.RequestTimeout)
+				}
+			},
+		},
+		{
+			name:   "shares the seed",
+			target: `{"name": "eu"}`,
+			seed:   42,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Seed != 42 {
+					t.Errorf("seed = %d, want 42", cfg.Seed)
+				}
+			},
+		},
+		{
+			name:   "keeps its own seed",
+			target: `{"name": "eu", "seed": 7}`,
+			seed:   42,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Seed != 7 {
+					t.Errorf("seed = %d, want 7", cfg.Seed)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadConfig(t, strings.Replace(base, "%s", tt.target, 1))
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			cfg.Seed = tt.seed
+			merged, err := cfg.ForTarget(cfg.Targets[0])
+			if err != nil {
+				t.Fatalf("ForTarget() error = %v", err)
+			}
+			if merged.Targets != nil || merged.ConcurrentTargets {
+				t.Errorf("targets not cleared: %v, %v", merged.Targets, merged.ConcurrentTargets)
+			}
+			if cfg.HTTPServerAddress != "http://gateway.example.com" {
+				t.Errorf("base configuration changed: %q", cfg.HTTPServerAddress)
+			}
+			tt.check(t, merged)
+		})
+	}
+}
+
+func TestTargetValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets string
+		wantErr string
+	}{
+		{name: "valid", targets: `[{"name": "us"}, {"name": "eu", "concurrency": 4}]`},
+		{name: "missing name", targets: `[{"httpAddress": "http://eu.example.com"}]`, wantErr: "targets require a name"},
+		{name: "duplicate name", targets: `[{"name": "eu"}, {"name": "eu"}]`, wantErr: "target eu is defined twice"},
+		{name: "invalid override", targets: `[{"name": "eu", "rate": -1}]`, wantErr: "target eu: rate must not be negative"},
+		{name: "conflicting override", targets: `[{"name": "eu", "endpoints": [{"httpAddress": "http://eu.example.com"}]}]`, wantErr: "target eu: endpoints replace httpAddress and grpcAddress"},
+		{name: "mistyped override", targets: `[{"name": "eu", "concurrency": "4"}]`, wantErr: "target eu:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfig(t, `{"httpAddress": "http://gateway.example.com", "csv": "keys.csv", "targets": `+tt.targets+`}`)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("LoadConfig() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)):
+				t.Errorf("LoadConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
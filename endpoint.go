@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/ParkerData/parkbench/config"
+	parker_pb "github.com/ParkerData/parkbench/pb/parker_pb"
+	"github.com/ParkerData/parkbench/stats"
+	"google.golang.org/grpc"
+)
+
+// endpoint is one server requests are sent to, with the clients to reach it
+type endpoint struct {
+	name        string
+	httpAddress string
+	grpcAddress string
+	weight      int
+	httpClient  *http.Client
+	// grpc is shared by all workers; nil when every worker dials its own
+	// connection to the single configured server
+	grpc        *grpcPool
+	outstanding atomic.Int64
+}
+
+// client returns the gRPC client of the next connection, from own when the
+// endpoint has no shared connections
+func (e *endpoint) client(own *grpcPool) parker_pb.GatewayClient {
+	if e.grpc == nil {
+		return own.client()
+	}
+	return e.grpc.client()
+}
+
+// balancer picks the endpoint of every send by the configured policy
+type balancer struct {
+	policy      string
+	endpoints   []*endpoint
+	totalWeight int
+	next        atomic.Uint64
+	// hedge receives the hedged copies when hedging to a separate server
+	hedge *endpoint
+	// record adds every send to the per-endpoint breakdown, which is only
+	// worth it with a list of endpoints
+	record bool
+}
+
+// newBalancer sets up the endpoints the benchmark sends to: the configured
+// list, resolved to addresses where asked, or the single server address
+func newBalancer(cfg *config.Config, useGRPC bool) (*balancer, error) {
+	b := &balancer{policy: cfg.Balance, record: len(cfg.Endpoints) > 0}
+	endpoints := cfg.Endpoints
+	if len(endpoints) == 0 {
+		endpoints = []config.Endpoint{{HTTPAddress: cfg.HTTPServerAddress, GRPCAddress: cfg.GRPCServerAddress, Weight: 1}}
+	}
+
+	var sharedClient *http.Client
+	for _, settings := range endpoints {
+		address := settings.HTTPAddress
+		if useGRPC {
+			address = settings.GRPCAddress
+		}
+		if address == "" {
+			return nil, fmt.Errorf("%s server address not provided in config", protocolName(useGRPC))
+		}
+		name := settings.Name
+		if name == "" {
+			name = address
+		}
+
+		dialAddresses := []string{""}
+		if settings.Resolve {
+			var err error
+			if dialAddresses, err = resolveAddress(address, useGRPC); err != nil {
+				return nil, err
+			}
+		}
+		for _, dialAddress := range dialAddresses {
+			e := &endpoint{name: name, httpAddress: settings.HTTPAddress, grpcAddress: settings.GRPCAddress, weight: settings.Weight}
+			if dialAddress != "" {
+				e.name = name + "/" + dialAddress
+			}
+
+			var err error
+			switch {
+			case useGRPC && dialAddress != "":
+				e.grpc, err = newGRPCPool(cfg, dialAddress, max(cfg.GRPC.Connections, 1), grpc.WithAuthority(hostPort(address)))
+			case useGRPC && (len(cfg.Endpoints) > 0 || cfg.GRPC.Connections > 0):
+				e.grpc, err = newGRPCPool(cfg, address, max(cfg.GRPC.Connections, 1))
+			case !useGRPC && dialAddress != "":
+				e.httpClient, err = newHTTPClient(cfg, dialAddress)
+			case !useGRPC:
+				if sharedClient == nil {
+					sharedClient, err = newHTTPClient(cfg, "")
+				}
+				e.httpClient = sharedClient
+			}
+			if err != nil {
+				b.Close()
+				return nil, err
+			}
+			b.endpoints = append(b.endpoints, e)
+			b.totalWeight += e.weight
+		}
+	}
+
+	if cfg.Hedge != nil {
+		hedge := &endpoint{name: cfg.Hedge.HTTPAddress, httpAddress: cfg.Hedge.HTTPAddress, grpcAddress: cfg.Hedge.GRPCAddress, weight: 1}
+		var err error
+		switch {
+		case useGRPC && hedge.grpcAddress != "":
+			hedge.name = hedge.grpcAddress
+			hedge.grpc, err = newGRPCPool(cfg, hedge.grpcAddress, max(cfg.GRPC.Connections, 1))
+			b.hedge = hedge
+		case !useGRPC && hedge.httpAddress != "":
+			hedge.httpClient, err = newHTTPClient(cfg, "")
+			b.hedge = hedge
+		}
+		if err != nil {
+			b.Close()
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func protocolName(useGRPC bool) string {
+	if useGRPC {
+		return "gRPC"
+	}
+	return "HTTP"
+}
+
+// resolveAddress looks up the host of a server address and returns every
+// address it resolves to with the port of the server
+func resolveAddress(address string, useGRPC bool) ([]string, error) {
+	target := address
+	if !useGRPC {
+		target = hostPort(address)
+	}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server address %q: %w", address, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	var addresses []string
+	for _, ip := range ips {
+		addresses = append(addresses, net.JoinHostPort(ip, port))
+	}
+	return addresses, nil
+}
+
+// hostPort returns the host and port of an HTTP URL, with the default port
+// of its scheme when absent; gRPC addresses are returned as they are
+func hostPort(address string) string {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return address
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+// pick returns the endpoint of the next send
+func (b *balancer) pick() *endpoint {
+	if len(b.endpoints) == 1 {
+		return b.endpoints[0]
+	}
+	switch b.policy {
+	case config.BalanceRandom:
+		return b.endpoints[rand.IntN(len(b.endpoints))]
+	case config.BalanceLeastOutstanding:
+		// Keep each of the tied endpoints with equal chance so ties are
+		// spread evenly
+		var best *endpoint
+		var fewest int64
+		ties := 0
+		for _, e := range b.endpoints {
+			switch n := e.outstanding.Load(); {
+			case best == nil || n < fewest:
+				best, fewest, ties = e, n, 1
+			case n == fewest:
+				ties++
+				if rand.IntN(ties) == 0 {
+					best = e
+				}
+			}
+		}
+		return best
+	case config.BalanceWeighted:
+		n := rand.IntN(b.totalWeight)
+		for _, e := range b.endpoints {
+			if n < e.weight {
+				return e
+			}
+			n -= e.weight
+		}
+	}
+	return b.endpoints[(b.next.Add(1)-1)%uint64(len(b.endpoints))]
+}
+
+// pickHedge returns the endpoint of a hedged copy: the separate hedge server
+// if configured, otherwise whichever endpoint the policy picks
+func (b *balancer) pickHedge() *endpoint {
+	if b.hedge != nil {
+		return b.hedge
+	}
+	return b.pick()
+}
+
+// send sends one copy of a request to e, counting it as outstanding while it
+// waits and adding it to the per-endpoint breakdown unless it was cancelled
+// because another copy won
+func (b *balancer) send(ctx context.Context, e *endpoint, recorder *stats.Recorder, send attemptFunc) (time.Duration, string, error) {
+	e.outstanding.Add(1)
+	start := time.Now()
+	latency, class, err := send()
+	e.outstanding.Add(-1)
+	if b.record && ctx.Err() == nil {
+		recorder.RecordEndpoint(e.name, time.Since(start), class)
+	}
+	return latency, class, err
+}
+
+// Close closes the gRPC connections of all endpoints
+func (b *balancer) Close() {
+	for _, e := range b.endpoints {
+		if e.grpc != nil {
+			e.grpc.Close()
+		}
+	}
+	if b.hedge != nil && b.hedge.grpc != nil {
+		b.hedge.grpc.Close()
+	}
+}
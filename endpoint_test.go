@@ -0,0 +1,106 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ParkerData/parkbench/config"
+)
+
+// newTestBalancer returns a balancer over endpoints named "0", "1", ... with the given weights
+func newTestBalancer(policy string, weights ...int) *balancer {
+	b := &balancer{policy: policy}
+	for i, weight := range weights {
+		b.endpoints = append(b.endpoints, &endpoint{name: string(rune('0' + i)), weight: weight})
+		b.totalWeight += weight
+	}
+	return b
+}
+
+// picks returns the fraction of n picks that went to each endpoint
+func picks(b *balancer, n int) []float64 {
+	counts := make(map[*endpoint]int)
+	for range n {
+		counts[b.pick()]++
+	}
+	fractions := make([]float64, len(b.endpoints))
+	for i, e := range b.endpoints {
+		fractions[i] = float64(counts[e]) / float64(n)
+	}
+	return fractions
+}
+
+func TestBalancerSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		weights []int
+		want    []float64
+	}{
+		{name: "single endpoint", policy: config.BalanceWeighted, weights: []int{5}, want: []float64{1}},
+		{name: "round robin", policy: config.BalanceRoundRobin, weights: []int{1, 1, 1, 1}, want: []float64{0.25, 0.25, 0.25, 0.25}},
+		{name: "round robin ignores weights", policy: config.BalanceRoundRobin, weights: []int{3, 1}, want: []float64{0.5, 0.5}},
+		{name: "random", policy: config.BalanceRandom, weights: []int{1, 1}, want: []float64{0.5, 0.5}},
+		{name: "equal weights", policy: config.BalanceWeighted, weights: []int{1, 1}, want: []float64{0.5, 0.5}},
+		{name: "weighted", policy: config.BalanceWeighted, weights: []int{3, 1}, want: []float64{0.75, 0.25}},
+		{name: "three weights", policy: config.BalanceWeighted, weights: []int{1, 2, 7}, want: []float64{0.1, 0.2, 0.7}},
+		{name: "zero weight", policy: config.BalanceWeighted, weights: []int{2, 0, 2}, want: []float64{0.5, 0, 0.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, got := range picks(newTestBalancer(tt.policy, tt.weights...), 100000) {
+				if math.Abs(got-tt.want[i]) > 0.01 {
+					t.Errorf("endpoint %d picked %.3f of the time, want %.3f", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBalancerLeastOutstanding(t *testing.T) {
+	tests := []struct {
+		name        string
+		outstanding []int64
+		want        []float64
+	}{
+		{name: "fewest in flight", outstanding: []int64{4, 1, 3}, want: []float64{0, 1, 0}},
+		{name: "idle endpoint", outstanding: []int64{2, 2, 0}, want: []float64{0, 0, 1}},
+		{name: "ties spread evenly", outstanding: []int64{1, 5, 1}, want: []float64{0.5, 0, 0.5}},
+		{name: "all equal", outstanding: []int64{0, 0, 0, 0}, want: []float64{0.25, 0.25, 0.25, 0.25}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBalancer(config.BalanceLeastOutstanding, make([]int, len(tt.outstanding))...)
+			for i, n := range tt.outstanding {
+				b.endpoints[i].outstanding.Store(n)
+			}
+			for i, got := range picks(b, 100000) {
+				if math.Abs(got-tt.want[i]) > 0.01 {
+					t.Errorf("endpoint %d picked %.3f of the time, want %.3f", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHostPort(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{address: "http://gateway.example.com", want: "gateway.example.com:80"},
+		{address: "http://gateway.example.com/prefix", want: "gateway.example.com:80"},
+		{address: "https://gateway.example.com", want: "gateway.example.com:443"},
+		{address: "http://gateway.example.com:8080", want: "gateway.example.com:8080"},
+		{address: "https://gateway.example.com:8443", want: "gateway.example.com:8443"},
+		{address: "http://[::1]", want: "[::1]:80"},
+		{address: "https://[::1]:8443", want: "[::1]:8443"},
+		{address: "gateway.example.com:50051", want: "gateway.example.com:50051"},
+		{address: "10.0.0.1:50051", want: "10.0.0.1:50051"},
+	}
+	for _, tt := range tests {
+		if got := hostPort(tt.address); got != tt.want {
+			t.Errorf("hostPort(%q) = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ParkerData/parkbench/config"
+	"github.com/ParkerData/parkbench/stats"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// minErrorRateSamples avoids aborting on the error rate of the first few requests
+const minErrorRateSamples = 100
+
+// loggedErrorClasses remembers which error classes have already been logged
+var loggedErrorClasses sync.Map
+
+// recordError counts a failed request and logs the first error of each class
+func recordError(recorder *stats.Recorder, class string, err error) {
+	recorder.RecordError(class)
+	logFirstError(class, err)
+}
+
+func logFirstError(class string, err error) {
+	if _, logged := loggedErrorClasses.LoadOrStore(class, true); !logged {
+		log.Printf("First %s error: %v", class, err)
+	}
+}
+
+// recordOutcome records a request given the error class of its failure, ""
+// when it succeeded, and returns the class it was counted under, "" for a hit
+// and stats.ErrorMiss for an expected miss; the latency of a timeout is how
+// long the request waited before giving up
+func recordOutcome(recorder *stats.Recorder, req request, latency time.Duration, class string, err error) string {
+	switch {
+	case class == "" && req.expectMiss:
+		recordError(recorder, stats.ErrorUnexpectedHit, fmt.Errorf("key %v expected to be missing was found", req.key))
+		return stats.ErrorUnexpectedHit
+	case class == "" && req.unhedged:
+		recorder.RecordUnhedged(latency)
+	case class == "":
+		recorder.Record(latency)
+	case class == stats.ErrorMiss && req.expectMiss:
+		recorder.RecordMiss(latency)
+	case class == stats.ErrorTimeout:
+		recorder.RecordTimeout(latency)
+		logFirstError(class, err)
+	default:
+		recordError(recorder, class, err)
+	}
+	return class
+}
+
+// classifyTransportError distinguishes timeouts from other failures to reach the server
+func classifyTransportError(err error) string {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return stats.ErrorTimeout
+	}
+	return stats.ErrorTransport
+}
+
+// classifyHTTPStatus returns the error class of a non-200 HTTP response
+func classifyHTTPStatus(code int) string {
+	if code == http.StatusNotFound {
+		return stats.ErrorMiss
+	}
+	return fmt.Sprintf("http_%d", code)
+}
+
+// classifyGRPCError returns the error class of a failed gRPC call
+func classifyGRPCError(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return classifyTransportError(err)
+	}
+	switch st.Code() {
+	case codes.NotFound:
+		return stats.ErrorMiss
+	case codes.DeadlineExceeded:
+		return stats.ErrorTimeout
+	}
+	return "grpc_" + st.Code().String()
+}
+
+// hasErrorBudget reports whether the run is aborted past some number or rate of errors
+func hasErrorBudget(cfg *config.Config) bool {
+	return cfg.MaxErrors > 0 || cfg.MaxErrorRate > 0
+}
+
+// checkErrorBudget returns an error once the run has failed more requests than
+// allowed; misses are left out
+func checkErrorBudget(cfg *config.Config, s stats.Snapshot) error {
+	errors := s.BudgetErrorCount()
+	if cfg.MaxErrors > 0 && errors > cfg.MaxErrors {
+		return fmt.Errorf("%d errors exceed maxErrors %d", errors, cfg.MaxErrors)
+	}
+	if completed := s.Completed(); cfg.MaxErrorRate > 0 && completed >= minErrorRateSamples {
+		if rate := float64(errors) / float64(completed); rate > cfg.MaxErrorRate {
+			return fmt.Errorf("error rate %.3f%% exceeds maxErrorRate %.3f%%", rate*100, cfg.MaxErrorRate*100)
+		}
+	}
+	return nil
+}
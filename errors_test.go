@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ParkerData/parkbench/config"
+	"github.com/ParkerData/parkbench/stats"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassifyHTTPStatus(t *testing.T) {
+	tests := []struct {
+		code int
+		want string
+	}{
+		{code: 404, want: stats.ErrorMiss},
+		{code: 401, want: "http_401"},
+		{code: 429, want: "http_429"},
+		{code: 503, want: "http_503"},
+	}
+	for _, tt := range tests {
+		if got := classifyHTTPStatus(tt.code); got != tt.want {
+			t.Errorf("classifyHTTPStatus(%d) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyGRPCError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "not found", err: status.Error(codes.NotFound, "no record"), want: stats.ErrorMiss},
+		{name: "deadline", err: status.Error(codes.DeadlineExceeded, "deadline"), want: stats.ErrorTimeout},
+		{name: "unavailable", err: status.Error(codes.Unavailable, "down"), want: "grpc_Unavailable"},
+		{name: "unauthenticated", err: status.Error(codes.Unauthenticated, "token"), want: "grpc_Unauthenticated"},
+		{name: "context deadline", err: context.DeadlineExceeded, want: stats.ErrorTimeout},
+		{name: "transport", err: errors.New("connection reset"), want: stats.ErrorTransport},
+	}
+	for _, tt := range tests {
+		if got := classifyGRPCError(tt.err); got != tt.want {
+			t.Errorf("%s: classifyGRPCError() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyTransportError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "context deadline", err: fmt.Errorf("Get: %w", context.DeadlineExceeded), want: stats.ErrorTimeout},
+		{name: "net timeout", err: &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, want: stats.ErrorTimeout},
+		{name: "refused", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: stats.ErrorTransport},
+		{name: "cancelled", err: context.Canceled, want: stats.ErrorTransport},
+	}
+	for _, tt := range tests {
+		if got := classifyTransportError(tt.err); got != tt.want {
+			t.Errorf("%s: classifyTransportError() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecordOutcome(t *testing.T) {
+	failure := errors.New("failed")
+	tests := []struct {
+		name       string
+		req        request
+		class      string
+		want       string
+		successful int64
+		errors     map[string]int64
+	}{
+		{name: "hit", want: "", successful: 1},
+		{name: "expected miss", req: request{expectMiss: true}, class: stats.ErrorMiss, want: stats.ErrorMiss, successful: 1},
+		{name: "unexpected miss", class: stats.ErrorMiss, want: stats.ErrorMiss, errors: map[string]int64{stats.ErrorMiss: 1}},
+		{name: "unexpected hit", req: request{expectMiss: true}, want: stats.ErrorUnexpectedHit, errors: map[string]int64{stats.ErrorUnexpectedHit: 1}},
+		{name: "unhedged hit", req: request{unhedged: true}, want: "", successful: 1},
+		{name: "timeout", class: stats.ErrorTimeout, want: stats.ErrorTimeout, errors: map[string]int64{stats.ErrorTimeout: 1}},
+		{name: "server error", class: "http_503", want: "http_503", errors: map[string]int64{"http_503": 1}},
+	}
+	for _, tt := range tests {
+		recorder := stats.NewRecorder()
+		if got := recordOutcome(recorder, tt.req, time.Millisecond, tt.class, failure); got != tt.want {
+			t.Errorf("%s: recordOutcome() = %q, want %q", tt.name, got, tt.want)
+		}
+		s := recorder.Summary()
+		if s.Successful() != tt.successful {
+			t.Errorf("%s: %d successful requests, want %d", tt.name, s.Successful(), tt.successful)
+		}
+		if !maps.Equal(s.Errors, tt.errors) {
+			t.Errorf("%s: errors = %v, want %v", tt.name, s.Errors, tt.errors)
+		}
+	}
+}
+
+func TestCheckErrorBudget(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxErrors    int64
+		maxErrorRate float64
+		requests     int64
+		errors       map[string]int64
+		wantErr      string
+	}{
+		{name: "unlimited", requests: 10, errors: map[string]int64{"http_503": 90}},
+		{name: "within maxErrors", maxErrors: 5, requests: 100, errors: map[string]int64{"http_503": 3, "timeout": 2}},
+		{name: "over maxErrors", maxErrors: 5, requests: 100, errors: map[string]int64{"http_503": 3, "timeout": 3}, wantErr: "6 errors exceed maxErrors 5"},
+		{name: "misses left out of maxErrors", maxErrors: 5, requests: 100, errors: map[string]int64{stats.ErrorMiss: 50, "http_503": 5}},
+		{name: "within maxErrorRate", maxErrorRate: 0.1, requests: 180, errors: map[string]int64{"http_503": 20}},
+		{name: "over maxErrorRate", maxErrorRate: 0.1, requests: 170, errors: map[string]int64{"http_503": 30}, wantErr: "error rate 15.000% exceeds maxErrorRate 10.000%"},
+		{name: "misses left out of maxErrorRate", maxErrorRate: 0.1, requests: 100, errors: map[string]int64{stats.ErrorMiss: 100}},
+		{name: "too few samples for maxErrorRate", maxErrorRate: 0.1, requests: 10, errors: map[string]int64{"http_503": 50}},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{MaxErrors: tt.maxErrors, MaxErrorRate: tt.maxErrorRate}
+		err := checkErrorBudget(cfg, stats.Snapshot{Requests: tt.requests, Errors: tt.errors})
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: checkErrorBudget() error = %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: checkErrorBudget() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ParkerData/parkbench/config"
+	parker_pb "github.com/ParkerData/parkbench/pb/parker_pb"
+)
+
+// findParams are the optional FindRequest fields besides the key
+type findParams struct {
+	partitions []*parker_pb.Partition
+	keyColumn  string
+	snapshot   int64
+	columns    []string
+}
+
+// newFindParams returns the FindRequest fields configured for every request
+func newFindParams(cfg *config.Config) *findParams {
+	params := &findParams{
+		keyColumn: cfg.KeyColumn,
+		snapshot:  cfg.Snapshot,
+		columns:   cfg.Columns,
+	}
+	for _, key := range slices.Sorted(maps.Keys(cfg.Partitions)) {
+		params.partitions = append(params.partitions, &parker_pb.Partition{
+			PartitionKey:   key,
+			PartitionValue: cfg.Partitions[key],
+		})
+	}
+	return params
+}
+
+// withRow overrides the configured fields with the values of a CSV row
+// according to the csvColumns layout
+func (p *findParams) withRow(columns []string, record []string) (*findParams, error) {
+	if len(columns) <= 1 {
+		return p, nil
+	}
+
+	params := *p
+	params.partitions = slices.Clone(p.partitions)
+	for i, column := range columns {
+		if i >= len(record) {
+			return nil, fmt.Errorf("row has %d fields, csvColumns expects %d", len(record), len(columns))
+		}
+		value := record[i]
+
+		switch {
+		case column == config.ColumnKeyColumn:
+			params.keyColumn = value
+		case column == config.ColumnSnapshot:
+			if value == "" {
+				continue
+			}
+			snapshot, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid snapshot %q: %w", value, err)
+			}
+			params.snapshot = snapshot
+		case column == config.ColumnColumns:
+			params.columns = nil
+			if value != "" {
+				params.columns = strings.Split(value, ",")
+			}
+		case strings.HasPrefix(column, config.ColumnPartitionPrefix):
+			params.setPartition(strings.TrimPrefix(column, config.ColumnPartitionPrefix), value)
+		}
+	}
+	return &params, nil
+}
+
+func (p *findParams) setPartition(key, value string) {
+	for i, partition := range p.partitions {
+		if partition.PartitionKey == key {
+			p.partitions[i] = &parker_pb.Partition{PartitionKey: key, PartitionValue: value}
+			return
+		}
+	}
+	p.partitions = append(p.partitions, &parker_pb.Partition{PartitionKey: key, PartitionValue: value})
+}
+
+// parseKey converts an ID from the input into the Key variant of keyType
+func parseKey(id string, keyType string, encoding string) (*parker_pb.Key, error) {
+	switch keyType {
+	case config.KeyInt32:
+		v, err := strconv.ParseInt(id, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int32 key %q: %w", id, err)
+		}
+		return &parker_pb.Key{Kind: &parker_pb.Key_Int32Value{Int32Value: int32(v)}}, nil
+	case config.KeyInt64:
+		v, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int64 key %q: %w", id, err)
+		}
+		return &parker_pb.Key{Kind: &parker_pb.Key_Int64Value{Int64Value: v}}, nil
+	case config.KeyBytes:
+		var v []byte
+		var err error
+		if encoding == config.EncodingBase64 {
+			v, err = base64.StdEncoding.DecodeString(id)
+		} else {
+			v, err = hex.DecodeString(id)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s bytes key %q: %w", encoding, id, err)
+		}
+		return &parker_pb.Key{Kind: &parker_pb.Key_BytesValue{BytesValue: v}}, nil
+	default:
+		return &parker_pb.Key{Kind: &parker_pb.Key_StringValue{StringValue: id}}, nil
+	}
+}
+
+// keyPath encodes a key as the path segment of the HTTP find endpoint and
+// returns the key type to send alongside it, empty for string keys
+func keyPath(key *parker_pb.Key) (string, string) {
+	switch kind := key.Kind.(type) {
+	case *parker_pb.Key_Int32Value:
+		return strconv.FormatInt(int64(kind.Int32Value), 10), config.KeyInt32
+	case *parker_pb.Key_Int64Value:
+		return strconv.FormatInt(kind.Int64Value, 10), config.KeyInt64
+	case *parker_pb.Key_BytesValue:
+		return hex.EncodeToString(kind.BytesValue), config.KeyBytes
+	default:
+		return url.PathEscape(key.GetStringValue()), ""
+	}
+}
+
+// findRequest builds the gRPC request for a key
+func (p *findParams) findRequest(cfg *config.Config, key *parker_pb.Key) *parker_pb.FindRequest {
+	return &parker_pb.FindRequest{
+		Account:    cfg.AccountName,
+		Table:      cfg.TableName,
+		Partitions: p.partitions,
+		Key:        key,
+		KeyColumn:  p.keyColumn,
+		Snapshot:   p.snapshot,
+		Columns:    p.columns,
+	}
+}
+
+// query encodes the fields as query parameters of the HTTP find endpoint
+func (p *findParams) query(keyType string) string {
+	values := url.Values{}
+	if keyType != "" {
+		values.Set("key_type", keyType)
+	}
+	for _, partition := range p.partitions {
+		values.Set("partition."+partition.PartitionKey, partition.PartitionValue)
+	}
+	if p.keyColumn != "" {
+		values.Set("key_column", p.keyColumn)
+	}
+	if p.snapshot != 0 {
+		values.Set("snapshot", strconv.FormatInt(p.snapshot, 10))
+	}
+	for _, column := range p.columns {
+		values.Add("columns", column)
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return "?" + values.Encode()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ParkerData/parkbench/config"
+	parker_pb "github.com/ParkerData/parkbench/pb/parker_pb"
+	"github.com/ParkerData/parkbench/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+// grpcPool holds gRPC connections handed out round-robin, one call at a time
+type grpcPool struct {
+	conns   []*grpc.ClientConn
+	clients []parker_pb.GatewayClient
+	next    atomic.Uint64
+}
+
+// newGRPCPool opens size connections to the gRPC server at address, with
+// extra dial options on top of the configured ones
+func newGRPCPool(cfg *config.Config, address string, size int, extra ...grpc.DialOption) (*grpcPool, error) {
+	options, err := grpcDialOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	options = append(options, extra...)
+	pool := &grpcPool{}
+	for range size {
+		conn, err := grpc.NewClient(address, options...)
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		pool.conns = append(pool.conns, conn)
+		pool.clients = append(pool.clients, parker_pb.NewGatewayClient(conn))
+	}
+	return pool, nil
+}
+
+// client returns the client of the next connection
+func (p *grpcPool) client() parker_pb.GatewayClient {
+	if len(p.clients) == 1 {
+		return p.clients[0]
+	}
+	return p.clients[(p.next.Add(1)-1)%uint64(len(p.clients))]
+}
+
+// Close closes all connections
+func (p *grpcPool) Close() {
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+}
+
+// grpcDialOptions builds the channel options from the configuration
+func grpcDialOptions(cfg *config.Config) ([]grpc.DialOption, error) {
+	creds := insecure.NewCredentials()
+	if !cfg.Plaintext {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	options := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithStatsHandler(tracing.StatsHandler{}),
+	}
+
+	channel := cfg.GRPC
+	if channel.KeepaliveTime.Duration > 0 {
+		options = append(options, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                channel.KeepaliveTime.Duration,
+			Timeout:             channel.KeepaliveTimeout.Duration,
+			PermitWithoutStream: channel.KeepalivePermitWithoutStream,
+		}))
+	}
+	var callOptions []grpc.CallOption
+	if channel.MaxRecvMessageSize > 0 {
+		callOptions = append(callOptions, grpc.MaxCallRecvMsgSize(channel.MaxRecvMessageSize))
+	}
+	if channel.MaxSendMessageSize > 0 {
+		callOptions = append(callOptions, grpc.MaxCallSendMsgSize(channel.MaxSendMessageSize))
+	}
+	if len(callOptions) > 0 {
+		options = append(options, grpc.WithDefaultCallOptions(callOptions...))
+	}
+	if channel.InitialWindowSize > 0 {
+		options = append(options, grpc.WithInitialWindowSize(channel.InitialWindowSize))
+	}
+	if channel.InitialConnWindowSize > 0 {
+		options = append(options, grpc.WithInitialConnWindowSize(channel.InitialConnWindowSize))
+	}
+	if channel.LoadBalancing != "" {
+		options = append(options, grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingConfig": [{%q: {}}]}`, channel.LoadBalancing)))
+	}
+	return options, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/ParkerData/parkbench/config"
+	"github.com/ParkerData/parkbench/stats"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// hedgeWindow is how often the live percentile delay is recomputed from
+	// the replies of the last window
+	hedgeWindow = time.Second
+	// minHedgeSamples keeps a window with few replies from setting the delay
+	minHedgeSamples = 100
+)
+
+// sendFunc sends a request once, to the hedge target when hedge is set, and
+// returns like httpFind and grpcFind; the send ends early when ctx is cancelled
+type sendFunc func(ctx context.Context, hedge bool) (time.Duration, string, error)
+
+// hedger sends a copy of requests whose reply is late, shared by all workers
+// so the live percentile covers every reply; a nil hedger sends every request once
+type hedger struct {
+	cfg *config.Hedge
+	// delay is the current live percentile delay in nanoseconds, 0 until known
+	delay atomic.Int64
+
+	mu        sync.Mutex
+	latencies *hdrhistogram.Histogram
+	since     time.Time
+}
+
+func newHedger(cfg *config.Hedge) *hedger {
+	if cfg == nil {
+		return nil
+	}
+	return &hedger{
+		cfg:       cfg,
+		latencies: hdrhistogram.New(1, int64(time.Hour/time.Microsecond), 3),
+		since:     time.Now(),
+	}
+}
+
+// control picks the requests of the control group, sent without hedging
+func (h *hedger) control() bool {
+	return h != nil && rand.Float64() < h.cfg.Control
+}
+
+// hedgeDelay returns how long to wait for a reply before hedging; false
+// means not to hedge yet because the live percentile is unknown
+func (h *hedger) hedgeDelay() (time.Duration, bool) {
+	if h.cfg.Percentile > 0 {
+		if delay := h.delay.Load(); delay > 0 {
+			return time.Duration(delay), true
+		}
+	}
+	return h.cfg.Delay.Duration, h.cfg.Delay.Duration > 0
+}
+
+// observe adds the time from sending a request to its first reply to the
+// live percentile
+func (h *hedger) observe(latency time.Duration) {
+	if h.cfg.Percentile == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.latencies.RecordValue(max(latency.Microseconds(), 1))
+	if time.Since(h.since) < hedgeWindow || h.latencies.TotalCount() < minHedgeSamples {
+		return
+	}
+	h.delay.Store(int64(time.Duration(h.latencies.ValueAtPercentile(h.cfg.Percentile)) * time.Microsecond))
+	h.latencies.Reset()
+	h.since = time.Now()
+}
+
+// sendResult is the outcome of one copy of a request
+type sendResult struct {
+	latency time.Duration
+	class   string
+	err     error
+	hedge   bool
+}
+
+// answered reports whether a copy got the reply the request waits for, a
+// record or a miss, rather than an error the other copy may still avoid
+func (r sendResult) answered() bool {
+	return r.class == "" || r.class == stats.ErrorMiss
+}
+
+// send sends a request and, once it is still waiting for a reply after the
+// hedge delay, a copy of it; the first answer wins and the other copy is
+// cancelled. When both copies fail the first failure is returned
+func (h *hedger) send(ctx context.Context, span trace.Span, recorder *stats.Recorder, unhedged bool, send sendFunc) (time.Duration, string, error) {
+	if h == nil || unhedged {
+		return send(ctx, false)
+	}
+	sent := time.Now()
+	delay, ok := h.hedgeDelay()
+	if !ok {
+		r := sendResult{}
+		r.latency, r.class, r.err = send(ctx, false)
+		if r.answered() {
+			h.observe(time.Since(sent))
+		}
+		recorder.RecordHedge(false, false)
+		return r.latency, r.class, r.err
+	}
+
+	results := make(chan sendResult, 2)
+	start := func(hedge bool) context.CancelFunc {
+		sendCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			latency, class, err := send(sendCtx, hedge)
+			results <- sendResult{latency: latency, class: class, err: err, hedge: hedge}
+		}()
+		return cancel
+	}
+	cancelPrimary := start(false)
+	defer cancelPrimary()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case r := <-results:
+		if r.answered() {
+			h.observe(time.Since(sent))
+		}
+		recorder.RecordHedge(false, false)
+		return r.latency, r.class, r.err
+	case <-timer.C:
+	}
+
+	span.AddEvent("hedge", trace.WithAttributes(attribute.Int64("parkbench.hedge_delay_us", delay.Microseconds())))
+	cancelHedge := start(true)
+	defer cancelHedge()
+
+	first := <-results
+	winner := first
+	if !first.answered() {
+		if second := <-results; second.answered() {
+			winner = second
+		}
+	}
+	won := winner.answered() && winner.hedge
+	if winner.answered() {
+		h.observe(time.Since(sent))
+	}
+	span.SetAttributes(attribute.Bool("parkbench.hedge_won", won))
+	recorder.RecordHedge(true, won)
+	return winner.latency, winner.class, winner.err
+}
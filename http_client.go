@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ParkerData/parkbench/config"
+	"golang.org/x/net/http2"
+)
+
+// newHTTPClient creates an HTTP client speaking the configured HTTP version;
+// it connects to dialAddress when set instead of the host of the URL, which
+// stays the name TLS verifies and the Host header
+func newHTTPClient(cfg *config.Config, dialAddress string) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{}
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if dialAddress != "" {
+			addr = dialAddress
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	var transport http.RoundTripper
+	switch cfg.HTTPVersion {
+	case config.HTTPVersion2:
+		t := &http2.Transport{
+			TLSClientConfig: tlsConfig,
+			IdleConnTimeout: 90 * time.Second,
+		}
+		if dialAddress != "" {
+			t.DialTLSContext = func(ctx context.Context, network, addr string, tlsConfig *tls.Config) (net.Conn, error) {
+				conn, err := dial(ctx, network, addr)
+				if err != nil {
+					return nil, err
+				}
+				tlsConn := tls.Client(conn, tlsConfig)
+				if err := tlsConn.HandshakeContext(ctx); err != nil {
+					conn.Close()
+					return nil, err
+				}
+				return tlsConn, nil
+			}
+		}
+		transport = t
+	case config.HTTPVersionH2C:
+		transport = &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(ctx, network, addr)
+			},
+			IdleConnTimeout: 90 * time.Second,
+		}
+	default:
+		t := &http.Transport{
+			MaxIdleConns:        1000,
+			MaxIdleConnsPerHost: 1000,
+			MaxConnsPerHost:     cfg.HTTPConnections,
+			IdleConnTimeout:     90 * time.Second,
+			DisableKeepAlives:   false,
+			TLSClientConfig:     tlsConfig,
+			// A custom TLS config would otherwise turn HTTP/2 off
+			ForceAttemptHTTP2: true,
+		}
+		if dialAddress != "" {
+			t.DialContext = dial
+		}
+		if cfg.HTTPVersion == config.HTTPVersion1 {
+			// A non-nil map keeps TLS from negotiating HTTP/2
+			t.ForceAttemptHTTP2 = false
+			t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		}
+		transport = t
+	}
+
+	// Requests are bounded by the requestTimeout deadline of their context
+	return &http.Client{Transport: transport}, nil
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+
+	"github.com/ParkerData/parkbench/config"
+	"github.com/ParkerData/parkbench/keys"
+)
+
+// input reads the CSV file or generated keys pass after pass in the configured order
+type input struct {
+	cfg    *config.Config
+	rng    *rand.Rand
+	header []string
+	// rows holds the generated keys, or the CSV file or a sample of it for
+	// the in-memory shuffles; nil when streaming the CSV file
+	rows keys.Rows
+	// misses mixes keys expected to be missing into the rows; nil for none
+	misses *missKeys
+}
+
+// openInput reads the header and, for the in-memory shuffles, the rows to send
+func openInput(cfg *config.Config, rng *rand.Rand) (*input, error) {
+	in, err := openRows(cfg, rng)
+	if err != nil {
+		return nil, err
+	}
+	in.misses, err = newMissKeys(cfg, rng)
+	if err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// openRows sets up the keys to look up from the CSV file or the generator
+func openRows(cfg *config.Config, rng *rand.Rand) (*input, error) {
+	if cfg.Generate != nil {
+		return openGenerated(cfg, rng)
+	}
+
+	src, err := keys.OpenCSV(cfg.CSVFilePath, cfg.CSVHeader)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	in := &input{cfg: cfg, rng: rng, header: src.Header()}
+	var rows keys.SliceRows
+	switch cfg.Shuffle {
+	case config.ShuffleFull:
+		rows, err = keys.ReadAll(src)
+	case config.ShuffleReservoir:
+		rows, err = keys.ReservoirSample(src, cfg.SampleSize, rng)
+	default:
+		log.Printf("Streaming input from %s", cfg.CSVFilePath)
+		return in, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	println("input csv rows:", len(rows))
+
+	// Spread the popular ranks of the skewed distributions over the input;
+	// latest keeps the input order so the last rows stay the popular ones
+	switch cfg.Distribution {
+	case config.DistributionShuffle, config.DistributionSequential, config.DistributionLatest:
+	default:
+		keys.Shuffle(rows, rng)
+	}
+	in.rows = rows
+	return in, nil
+}
+
+// openGenerated sets up the synthetic keys, sampling them for the reservoir shuffle
+func openGenerated(cfg *config.Config, rng *rand.Rand) (*input, error) {
+	generate := cfg.Generate
+
+	var rows keys.Rows
+	switch generate.Type {
+	case config.GenerateRange:
+		rows = keys.Range{Start: generate.Start, Count: generate.Count}
+	case config.GenerateTemplate:
+		rows = keys.Template{Format: generate.Template, Start: generate.Start, Count: generate.Count}
+	case config.GenerateUUID:
+		rows = keys.UUIDs{Seed: cfg.Seed, Count: generate.Count}
+	case config.GenerateBytes:
+		rows = keys.RandomBytes{Seed: cfg.Seed, Length: generate.Length, Count: generate.Count}
+	}
+
+	if cfg.Shuffle == config.ShuffleReservoir {
+		sample, err := keys.ReservoirSample(keys.InOrder(rows), cfg.SampleSize, rng)
+		if err != nil {
+			return nil, err
+		}
+		rows = keys.SliceRows(sample)
+	}
+
+	println("generated keys:", rows.Len())
+	return &input{cfg: cfg, rng: rng, rows: rows}, nil
+}
+
+// distribution returns the picker over the rows, nil to pass over the input instead
+func (in *input) distribution() keys.Distribution {
+	if in.rows == nil {
+		return nil
+	}
+
+	n := in.rows.Len()
+	switch in.cfg.Distribution {
+	case config.DistributionUniform:
+		return keys.NewUniform(n, in.rng)
+	case config.DistributionZipfian:
+		return keys.NewZipfian(n, in.cfg.ZipfTheta, in.rng)
+	case config.DistributionLatest:
+		return keys.NewLatest(n, in.cfg.ZipfTheta, in.rng)
+	case config.DistributionHotspot:
+		return keys.NewHotspot(n, in.cfg.HotspotKeys, in.cfg.HotspotTraffic, in.rng)
+	case config.DistributionSequential:
+		return keys.NewSequential(n)
+	}
+	return nil
+}
+
+// pass returns the rows of one pass over the input and a function to release them
+func (in *input) pass() (keys.Source, func() error, error) {
+	if in.rows != nil {
+		release := func() error { return nil }
+		switch in.cfg.Shuffle {
+		case config.ShuffleWindow:
+			return keys.WindowShuffle(keys.InOrder(in.rows), in.cfg.ShuffleWindow, in.rng), release, nil
+		case config.ShuffleNone:
+			return keys.InOrder(in.rows), release, nil
+		}
+		return keys.Shuffled(in.rows, in.rng), release, nil
+	}
+
+	src, err := keys.OpenCSV(in.cfg.CSVFilePath, in.cfg.CSVHeader)
+	if err != nil {
+		return nil, nil, err
+	}
+	if in.cfg.Shuffle == config.ShuffleWindow {
+		return keys.WindowShuffle(src, in.cfg.ShuffleWindow, in.rng), src.Close, nil
+	}
+	return src, src.Close, nil
+}
+
+// inputColumns returns the role of each CSV column, resolving csvKeyColumn against the header
+func inputColumns(cfg *config.Config, header []string) ([]string, error) {
+	if cfg.CSVKeyColumn == "" {
+		return cfg.CSVColumns, nil
+	}
+
+	index := slices.Index(header, cfg.CSVKeyColumn)
+	if index < 0 {
+		n, err := strconv.Atoi(cfg.CSVKeyColumn)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("key column %q not found in the CSV header", cfg.CSVKeyColumn)
+		}
+		index = n
+	}
+	columns := make([]string, index+1)
+	columns[index] = config.ColumnKey
+	return columns, nil
+}
+
+// rowParser turns CSV rows into requests
+type rowParser struct {
+	cfg      *config.Config
+	columns  []string
+	keyIndex int
+	defaults *findParams
+}
+
+func newRowParser(cfg *config.Config, columns []string) *rowParser {
+	return &rowParser{
+		cfg:      cfg,
+		columns:  columns,
+		keyIndex: config.KeyIndex(columns),
+		defaults: newFindParams(cfg),
+	}
+}
+
+func (p *rowParser) parse(record []string) (request, error) {
+	if p.keyIndex >= len(record) {
+		return request{}, fmt.Errorf("row has no key column %d", p.keyIndex+1)
+	}
+	key, err := parseKey(record[p.keyIndex], p.cfg.KeyType, p.cfg.KeyEncoding)
+	if err != nil {
+		return request{}, err
+	}
+	params, err := p.defaults.withRow(p.columns, record)
+	if err != nil {
+		return request{}, err
+	}
+	expected, err := p.expected(record)
+	if err != nil {
+		return request{}, err
+	}
+	return request{key: key, params: params, expected: expected}, nil
+}
+
+// expected returns the values of the "expect:" columns; empty cells are not checked
+func (p *rowParser) expected(record []string) ([]expectedValue, error) {
+	var expected []expectedValue
+	for i, column := range p.columns {
+		name, valueType, ok := config.ExpectColumn(column)
+		if !ok || i >= len(record) || record[i] == "" {
+			continue
+		}
+		value, err := parseExpected(name, valueType, record[i])
+		if err != nil {
+			return nil, err
+		}
+		expected = append(expected, value)
+	}
+	return expected, nil
+}
+
+// produce sends the input rows to out, pass after pass, until the configured
+// repeats are done or the context ends
+func (in *input) produce(ctx context.Context, parser *rowParser, out chan<- request) error {
+	defer close(out)
+
+	if dist := in.distribution(); dist != nil {
+		return in.produceFrom(ctx, dist, parser, out)
+	}
+
+	for pass := 0; in.cfg.Duration.Duration > 0 || pass < in.cfg.RepeatTimes; pass++ {
+		src, release, err := in.pass()
+		if err != nil {
+			return fmt.Errorf("failed to open CSV file: %w", err)
+		}
+
+		rows := 0
+		for {
+			record, err := src.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				release()
+				return fmt.Errorf("failed to read CSV file: %w", err)
+			}
+			rows++
+
+			req, err := parser.parse(record)
+			if err != nil {
+				release()
+				return fmt.Errorf("failed to parse CSV row %d: %w", rows, err)
+			}
+			if !in.misses.send(ctx, out, req) {
+				release()
+				return nil
+			}
+		}
+		release()
+
+		if rows == 0 {
+			return fmt.Errorf("CSV file %s has no rows", in.cfg.CSVFilePath)
+		}
+	}
+	return nil
+}
+
+// produceFrom sends rows picked by dist, as many as the configured passes
+// over the input would send, or until the context ends
+func (in *input) produceFrom(ctx context.Context, dist keys.Distribution, parser *rowParser, out chan<- request) error {
+	if in.rows.Len() == 0 {
+		return fmt.Errorf("CSV file %s has no rows", in.cfg.CSVFilePath)
+	}
+
+	total := in.rows.Len() * in.cfg.RepeatTimes
+	for sent := 0; in.cfg.Duration.Duration > 0 || sent < total; sent++ {
+		i := dist.Next()
+		req, err := parser.parse(in.rows.Row(i))
+		if err != nil {
+			return fmt.Errorf("failed to parse CSV row %d: %w", i+1, err)
+		}
+		if !in.misses.send(ctx, out, req) {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package keys
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// Distribution picks the index of the next key to request out of a fixed number of keys
+type Distribution interface {
+	Next() int
+}
+
+// Uniform picks every key with the same probability, with replacement
+type Uniform struct {
+	n   int
+	rng *rand.Rand
+}
+
+// NewUniform creates a uniform distribution over n keys
+func NewUniform(n int, rng *rand.Rand) *Uniform {
+	return &Uniform{n: n, rng: rng}
+}
+
+// Next returns a random key index
+func (u *Uniform) Next() int {
+	return u.rng.IntN(u.n)
+}
+
+// Sequential scans the keys in order and wraps around
+type Sequential struct {
+	n    int
+	next int
+}
+
+// NewSequential creates a sequential scan over n keys
+func NewSequential(n int) *Sequential {
+	return &Sequential{n: n}
+}
+
+// Next returns the index following the previous one
+func (s *Sequential) Next() int {
+	i := s.next
+	s.next = (s.next + 1) % s.n
+	return i
+}
+
+// Hotspot sends a fraction of the traffic to a fraction of the keys, the
+// hot set being the first keys, and spreads the rest uniformly over the others
+type Hotspot struct {
+	n          int
+	hot        int
+	hotTraffic float64
+	rng        *rand.Rand
+}
+
+// NewHotspot creates a distribution where hotTraffic of the requests go to
+// hotKeys of the n keys, both given as fractions
+func NewHotspot(n int, hotKeys, hotTraffic float64, rng *rand.Rand) *Hotspot {
+	hot := min(n, max(1, int(math.Round(hotKeys*float64(n)))))
+	return &Hotspot{n: n, hot: hot, hotTraffic: hotTraffic, rng: rng}
+}
+
+// Next returns a key index from the hot or the cold set
+func (h *Hotspot) Next() int {
+	if h.hot == h.n || h.rng.Float64() < h.hotTraffic {
+		return h.rng.IntN(h.hot)
+	}
+	return h.hot + h.rng.IntN(h.n-h.hot)
+}
+
+// Zipfian picks keys with a probability proportional to 1/rank^theta, so the
+// first keys are the most popular. It follows the generator YCSB uses from
+// Gray et al., "Quickly Generating Billion-Record Synthetic Databases", which
+// also supports theta below 1
+type Zipfian struct {
+	n     int
+	theta float64
+	alpha float64
+	zetan float64
+	eta   float64
+	rng   *rand.Rand
+}
+
+// NewZipfian creates a Zipfian distribution over n keys; theta must be in (0, 1)
+// and YCSB uses 0.99. Setup is linear in n
+func NewZipfian(n int, theta float64, rng *rand.Rand) *Zipfian {
+	zeta2 := zeta(2, theta)
+	zetan := zeta(n, theta)
+	return &Zipfian{
+		n:     n,
+		theta: theta,
+		alpha: 1 / (1 - theta),
+		zetan: zetan,
+		eta:   (1 - math.Pow(2/float64(n), 1-theta)) / (1 - zeta2/zetan),
+		rng:   rng,
+	}
+}
+
+// Latest picks keys with the same Zipfian skew but makes the last keys, the
+// most recently added ones, the most popular, like the YCSB latest
+// distribution does for freshly inserted records
+type Latest struct {
+	zipfian *Zipfian
+}
+
+// NewLatest creates a latest distribution over n keys with the skew theta of
+// NewZipfian
+func NewLatest(n int, theta float64, rng *rand.Rand) *Latest {
+	return &Latest{zipfian: NewZipfian(n, theta, rng)}
+}
+
+// Next returns a key index, n-1 being the most popular
+func (l *Latest) Next() int {
+	return l.zipfian.n - 1 - l.zipfian.Next()
+}
+
+func zeta(n int, theta float64) float64 {
+	var sum float64
+	for i := 1; i <= n; i++ {
+		sum += 1 / math.Pow(float64(i), theta)
+	}
+	return sum
+}
+
+// Next returns a key index, 0 being the most popular
+func (z *Zipfian) Next() int {
+	u := z.rng.Float64()
+	uz := u * z.zetan
+	if uz < 1 {
+		return 0
+	}
+	if uz < 1+math.Pow(0.5, z.theta) {
+		return min(1, z.n-1)
+	}
+	return min(int(float64(z.n)*math.Pow(z.eta*u-z.eta+1, z.alpha)), z.n-1)
+}
@@ -0,0 +1,159 @@
+package keys
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+const samples = 200000
+
+// frequencies draws samples key indexes from d and counts each one
+func frequencies(t *testing.T, d Distribution, n int) []int {
+	t.Helper()
+	counts := make([]int, n)
+	for range samples {
+		i := d.Next()
+		if i < 0 || i >= n {
+			t.Fatalf("Next() = %d, want an index in [0, %d)", i, n)
+		}
+		counts[i]++
+	}
+	return counts
+}
+
+// checkFraction fails when count out of samples is further than 1% from want
+func checkFraction(t *testing.T, name string, count int, want float64) {
+	t.Helper()
+	if got := float64(count) / samples; math.Abs(got-want) > 0.01 {
+		t.Errorf("%s: got fraction %.4f, want %.4f", name, got, want)
+	}
+}
+
+func TestZipfianConstants(t *testing.T) {
+	// Reference values computed independently from the formulas of Gray et al.
+	tests := []struct {
+		n     int
+		theta float64
+		alpha float64
+		zetan float64
+		eta   float64
+	}{
+		{n: 10, theta: 0.5, alpha: 2, zetan: 5.0209978992926665, eta: 0.8375469431048996},
+		{n: 1000, theta: 0.99, alpha: 100, zetan: 7.728953217284729, eta: 0.07480608670689805},
+	}
+	for _, tt := range tests {
+		z := NewZipfian(tt.n, tt.theta, rand.New(rand.NewPCG(1, 2)))
+		for _, c := range []struct {
+			name      string
+			got, want float64
+		}{{"alpha", z.alpha, tt.alpha}, {"zetan", z.zetan, tt.zetan}, {"eta", z.eta, tt.eta}} {
+			if math.Abs(c.got-c.want) > 1e-9*math.Abs(c.want) {
+				t.Errorf("n=%d theta=%v: %s = %v, want %v", tt.n, tt.theta, c.name, c.got, c.want)
+			}
+		}
+	}
+}
+
+func TestZipfian(t *testing.T) {
+	tests := []struct {
+		n     int
+		theta float64
+	}{
+		{n: 10, theta: 0.5},
+		{n: 1000, theta: 0.5},
+		{n: 1000, theta: 0.99},
+		{n: 100000, theta: 0.99},
+	}
+	for _, tt := range tests {
+		z := NewZipfian(tt.n, tt.theta, rand.New(rand.NewPCG(1, 2)))
+
+		// The generator returns the two most popular ranks with their exact
+		// Zipfian probabilities and approximates the rest
+		counts := frequencies(t, z, tt.n)
+		checkFraction(t, "rank 0", counts[0], 1/z.zetan)
+		checkFraction(t, "rank 1", counts[1], math.Pow(0.5, tt.theta)/z.zetan)
+		if counts[0] <= counts[tt.n/2] || counts[tt.n/2] < counts[tt.n-1]/2 {
+			t.Errorf("n=%d theta=%v: counts not decreasing with rank: %d, %d, %d", tt.n, tt.theta, counts[0], counts[tt.n/2], counts[tt.n-1])
+		}
+	}
+}
+
+func TestZeta(t *testing.T) {
+	tests := []struct {
+		n     int
+		theta float64
+		want  float64
+	}{
+		{n: 1, theta: 0.99, want: 1},
+		{n: 2, theta: 0.5, want: 1 + 1/math.Sqrt2},
+		{n: 4, theta: 0.5, want: 1 + 1/math.Sqrt2 + 1/math.Sqrt(3) + 0.5},
+	}
+	for _, tt := range tests {
+		if got := zeta(tt.n, tt.theta); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("zeta(%d, %v) = %v, want %v", tt.n, tt.theta, got, tt.want)
+		}
+	}
+}
+
+func TestLatest(t *testing.T) {
+	const n = 1000
+	l := NewLatest(n, 0.99, rand.New(rand.NewPCG(1, 2)))
+	counts := frequencies(t, l, n)
+	checkFraction(t, "last key", counts[n-1], 1/l.zipfian.zetan)
+	checkFraction(t, "second to last key", counts[n-2], math.Pow(0.5, 0.99)/l.zipfian.zetan)
+	if counts[n-1] <= counts[0] {
+		t.Errorf("last key picked %d times, first key %d times", counts[n-1], counts[0])
+	}
+}
+
+func TestHotspot(t *testing.T) {
+	tests := []struct {
+		n          int
+		hotKeys    float64
+		hotTraffic float64
+		wantHot    int
+	}{
+		{n: 1000, hotKeys: 0.2, hotTraffic: 0.8, wantHot: 200},
+		{n: 10, hotKeys: 0.25, hotTraffic: 0.9, wantHot: 3},
+		{n: 10, hotKeys: 0.01, hotTraffic: 0.5, wantHot: 1},
+		{n: 100, hotKeys: 1, hotTraffic: 0.3, wantHot: 100},
+		{n: 100, hotKeys: 0.5, hotTraffic: 0, wantHot: 50},
+	}
+	for _, tt := range tests {
+		h := NewHotspot(tt.n, tt.hotKeys, tt.hotTraffic, rand.New(rand.NewPCG(1, 2)))
+		if h.hot != tt.wantHot {
+			t.Errorf("n=%d hotKeys=%v: %d hot keys, want %d", tt.n, tt.hotKeys, h.hot, tt.wantHot)
+			continue
+		}
+
+		counts := frequencies(t, h, tt.n)
+		hot := 0
+		for _, count := range counts[:h.hot] {
+			hot += count
+		}
+		wantTraffic := tt.hotTraffic
+		if h.hot == tt.n {
+			wantTraffic = 1
+		}
+		checkFraction(t, "hot traffic", hot, wantTraffic)
+	}
+}
+
+func TestSequential(t *testing.T) {
+	s := NewSequential(3)
+	for i, want := range []int{0, 1, 2, 0, 1} {
+		if got := s.Next(); got != want {
+			t.Errorf("call %d: Next() = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestUniform(t *testing.T) {
+	const n = 10
+	counts := frequencies(t, NewUniform(n, rand.New(rand.NewPCG(1, 2))), n)
+	for i, count := range counts {
+		checkFraction(t, fmt.Sprintf("key %d", i), count, 1.0/n)
+	}
+}
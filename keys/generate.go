@@ -0,0 +1,89 @@
+package keys
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+)
+
+// Range generates the decimal integers Start, Start+1, ..., Start+Count-1
+type Range struct {
+	Start int64
+	Count int
+}
+
+// Len returns the number of keys
+func (r Range) Len() int {
+	return r.Count
+}
+
+// Row returns the i-th integer
+func (r Range) Row(i int) []string {
+	return []string{strconv.FormatInt(r.Start+int64(i), 10)}
+}
+
+// Template formats the integers of a range with a fmt template such as "user-%08d"
+type Template struct {
+	Format string
+	Start  int64
+	Count  int
+}
+
+// Len returns the number of keys
+func (t Template) Len() int {
+	return t.Count
+}
+
+// Row returns the i-th formatted key
+func (t Template) Row(i int) []string {
+	return []string{fmt.Sprintf(t.Format, t.Start+int64(i))}
+}
+
+// UUIDs derives Count random version 4 UUIDs from Seed; the same seed always
+// yields the same list
+type UUIDs struct {
+	Seed  uint64
+	Count int
+}
+
+// Len returns the number of keys
+func (u UUIDs) Len() int {
+	return u.Count
+}
+
+// Row returns the i-th UUID
+func (u UUIDs) Row(i int) []string {
+	b := seededBytes(u.Seed, i, 16)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return []string{fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])}
+}
+
+// RandomBytes derives Count random keys of Length bytes from Seed, hex encoded
+type RandomBytes struct {
+	Seed   uint64
+	Length int
+	Count  int
+}
+
+// Len returns the number of keys
+func (r RandomBytes) Len() int {
+	return r.Count
+}
+
+// Row returns the i-th key
+func (r RandomBytes) Row(i int) []string {
+	return []string{hex.EncodeToString(seededBytes(r.Seed, i, r.Length))}
+}
+
+// seededBytes returns n bytes that only depend on the seed and the index
+func seededBytes(seed uint64, i int, n int) []byte {
+	rng := rand.NewPCG(seed, uint64(i))
+	b := make([]byte, 0, n+8)
+	for len(b) < n {
+		b = binary.LittleEndian.AppendUint64(b, rng.Uint64())
+	}
+	return b[:n]
+}
@@ -0,0 +1,116 @@
+package keys
+
+import (
+	"io"
+	"math/rand/v2"
+)
+
+// Rows is a set of rows that can be picked by index, held in memory or generated on demand
+type Rows interface {
+	Len() int
+	Row(i int) []string
+}
+
+// SliceRows are rows held in memory
+type SliceRows [][]string
+
+// Len returns the number of rows
+func (r SliceRows) Len() int {
+	return len(r)
+}
+
+// Row returns the row at index i
+func (r SliceRows) Row(i int) []string {
+	return r[i]
+}
+
+// indexSource yields rows in the order of a permutation of their indexes, or in order without one
+type indexSource struct {
+	rows  Rows
+	order *permutation
+	next  int
+}
+
+// InOrder returns a source over all rows in index order
+func InOrder(rows Rows) Source {
+	return &indexSource{rows: rows}
+}
+
+// Shuffled returns a source over all rows in random order; rows held in
+// memory are shuffled in place, generated rows through a permutation of their
+// indexes computed on the fly, so no memory is held per row
+func Shuffled(rows Rows, rng *rand.Rand) Source {
+	if slice, ok := rows.(SliceRows); ok {
+		Shuffle(slice, rng)
+		return InOrder(slice)
+	}
+	return &indexSource{rows: rows, order: newPermutation(rows.Len(), rng)}
+}
+
+// Next returns the next row
+func (s *indexSource) Next() ([]string, error) {
+	if s.next >= s.rows.Len() {
+		return nil, io.EOF
+	}
+	i := s.next
+	if s.order != nil {
+		i = s.order.at(i)
+	}
+	s.next++
+	return s.rows.Row(i), nil
+}
+
+// feistelRounds is the number of rounds of the permutation network
+const feistelRounds = 8
+
+// permutation is a random bijection of [0, n): a Feistel network over the
+// smallest even number of bits covering n, walking the cycle of an index
+// through the values at or above n until it comes back below n
+type permutation struct {
+	n        uint64
+	halfBits uint
+	mask     uint64
+	keys     [feistelRounds]uint64
+}
+
+func newPermutation(n int, rng *rand.Rand) *permutation {
+	halfBits := uint(1)
+	for halfBits < 32 && uint64(1)<<(2*halfBits) < uint64(n) {
+		halfBits++
+	}
+	p := &permutation{n: uint64(n), halfBits: halfBits, mask: 1<<halfBits - 1}
+	for i := range p.keys {
+		p.keys[i] = rng.Uint64()
+	}
+	return p
+}
+
+// at returns the index at position i of the permutation; as the network
+// covers less than 4n values, this takes fewer than 4 rounds of the network
+// on average
+func (p *permutation) at(i int) int {
+	x := uint64(i)
+	for {
+		x = p.encrypt(x)
+		if x < p.n {
+			return int(x)
+		}
+	}
+}
+
+func (p *permutation) encrypt(x uint64) uint64 {
+	left, right := x>>p.halfBits, x&p.mask
+	for _, key := range p.keys {
+		left, right = right, left^(mix(right^key)&p.mask)
+	}
+	return left<<p.halfBits | right
+}
+
+// mix scrambles the bits of x, the finalizer of SplitMix64
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ x>>31
+}
@@ -0,0 +1,74 @@
+package keys
+
+import (
+	"io"
+	"math/rand/v2"
+)
+
+// windowShuffle yields the rows of a source in random order using a buffer
+// of limited size, so rows only move within a window of the input
+type windowShuffle struct {
+	src    Source
+	rng    *rand.Rand
+	buffer [][]string
+	size   int
+	done   bool
+}
+
+// WindowShuffle wraps src so rows come out in random order within a sliding window of size rows
+func WindowShuffle(src Source, size int, rng *rand.Rand) Source {
+	return &windowShuffle{src: src, rng: rng, size: size}
+}
+
+// Next returns a random row from the window and refills it from the source
+func (s *windowShuffle) Next() ([]string, error) {
+	for !s.done && len(s.buffer) < s.size {
+		row, err := s.src.Next()
+		if err == io.EOF {
+			s.done = true
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.buffer = append(s.buffer, row)
+	}
+	if len(s.buffer) == 0 {
+		return nil, io.EOF
+	}
+
+	i := s.rng.IntN(len(s.buffer))
+	last := len(s.buffer) - 1
+	row := s.buffer[i]
+	s.buffer[i] = s.buffer[last]
+	s.buffer[last] = nil
+	s.buffer = s.buffer[:last]
+	return row, nil
+}
+
+// ReservoirSample picks n rows uniformly at random from src in a single pass
+func ReservoirSample(src Source, n int, rng *rand.Rand) ([][]string, error) {
+	sample := make([][]string, 0, n)
+	for seen := 0; ; seen++ {
+		row, err := src.Next()
+		if err == io.EOF {
+			return sample, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if seen < n {
+			sample = append(sample, row)
+		} else if j := rng.IntN(seen + 1); j < n {
+			sample[j] = row
+		}
+	}
+}
+
+// Shuffle randomizes the order of rows in place with a Fisher–Yates shuffle
+func Shuffle(rows [][]string, rng *rand.Rand) {
+	rng.Shuffle(len(rows), func(i, j int) {
+		rows[i], rows[j] = rows[j], rows[i]
+	})
+}
@@ -0,0 +1,191 @@
+package keys
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// numbered returns the rows "0" to "n-1"
+func numbered(n int) SliceRows {
+	rows := make(SliceRows, n)
+	for i := range rows {
+		rows[i] = []string{strconv.Itoa(i)}
+	}
+	return rows
+}
+
+// drain reads every row of src and returns their numbers in the order read
+func drain(t *testing.T, src Source) []int {
+	t.Helper()
+	rows, err := ReadAll(src)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	var order []int
+	for _, row := range rows {
+		i, err := strconv.Atoi(row[0])
+		if err != nil {
+			t.Fatalf("unexpected row %v", row)
+		}
+		order = append(order, i)
+	}
+	return order
+}
+
+// checkOnce fails unless order holds every number below n exactly once
+func checkOnce(t *testing.T, order []int, n int) {
+	t.Helper()
+	if len(order) != n {
+		t.Fatalf("got %d rows, want %d", len(order), n)
+	}
+	seen := make([]bool, n)
+	for _, i := range order {
+		if i < 0 || i >= n || seen[i] {
+			t.Fatalf("row %d returned twice or out of range", i)
+		}
+		seen[i] = true
+	}
+}
+
+func TestWindowShuffle(t *testing.T) {
+	tests := []struct {
+		rows int
+		size int
+	}{
+		{rows: 0, size: 10},
+		{rows: 1, size: 10},
+		{rows: 100, size: 1},
+		{rows: 100, size: 10},
+		{rows: 100, size: 100},
+		{rows: 100, size: 1000},
+	}
+	for _, tt := range tests {
+		src := WindowShuffle(InOrder(numbered(tt.rows)), tt.size, rand.New(rand.NewPCG(1, 2)))
+		order := drain(t, src)
+		checkOnce(t, order, tt.rows)
+
+		// A row cannot come out before the window has reached it
+		for position, i := range order {
+			if i > position+tt.size-1 {
+				t.Errorf("rows=%d size=%d: row %d returned at position %d", tt.rows, tt.size, i, position)
+			}
+		}
+		if tt.size == 1 && !slices.IsSorted(order) {
+			t.Errorf("window of 1 reordered rows: %v", order)
+		}
+	}
+}
+
+// generated are the rows "0" to "n-1" produced on demand
+type generated int
+
+func (g generated) Len() int {
+	return int(g)
+}
+
+func (g generated) Row(i int) []string {
+	return []string{strconv.Itoa(i)}
+}
+
+func TestShuffled(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 3, 4, 5, 17, 1000, 4096, 100003} {
+		checkOnce(t, drain(t, Shuffled(numbered(n), rand.New(rand.NewPCG(1, 2)))), n)
+
+		order := drain(t, Shuffled(generated(n), rand.New(rand.NewPCG(1, 2))))
+		checkOnce(t, order, n)
+		if n >= 1000 && slices.IsSorted(order) {
+			t.Errorf("%d generated rows not shuffled", n)
+		}
+	}
+}
+
+func TestShuffledPasses(t *testing.T) {
+	// Every pass draws a new permutation
+	rng := rand.New(rand.NewPCG(1, 2))
+	first := drain(t, Shuffled(generated(1000), rng))
+	second := drain(t, Shuffled(generated(1000), rng))
+	if slices.Equal(first, second) {
+		t.Error("two passes returned the rows in the same order")
+	}
+}
+
+func TestPermutationPositions(t *testing.T) {
+	// Each row lands at every position about equally often across seeds
+	const (
+		n      = 10
+		trials = 20000
+	)
+	counts := make([][n]int, n)
+	for seed := range uint64(trials) {
+		p := newPermutation(n, rand.New(rand.NewPCG(seed, 1)))
+		for position := range n {
+			counts[p.at(position)][position]++
+		}
+	}
+	for row := range n {
+		for position, count := range counts[row] {
+			if count < 1700 || count > 2300 {
+				t.Errorf("row %d at position %d %d times, want about %d", row, position, count, trials/n)
+			}
+		}
+	}
+}
+
+func TestReservoirSample(t *testing.T) {
+	tests := []struct {
+		rows int
+		n    int
+		want int
+	}{
+		{rows: 0, n: 10, want: 0},
+		{rows: 5, n: 10, want: 5},
+		{rows: 10, n: 10, want: 10},
+		{rows: 1000, n: 10, want: 10},
+		{rows: 1000, n: 0, want: 0},
+	}
+	for _, tt := range tests {
+		sample, err := ReservoirSample(InOrder(numbered(tt.rows)), tt.n, rand.New(rand.NewPCG(1, 2)))
+		if err != nil {
+			t.Fatalf("ReservoirSample() error = %v", err)
+		}
+		order := drain(t, InOrder(SliceRows(sample)))
+		if len(order) != tt.want {
+			t.Errorf("rows=%d n=%d: sampled %d rows, want %d", tt.rows, tt.n, len(order), tt.want)
+		}
+		seen := make(map[int]bool)
+		for _, i := range order {
+			if i < 0 || i >= tt.rows || seen[i] {
+				t.Errorf("rows=%d n=%d: row %d sampled twice or out of range", tt.rows, tt.n, i)
+			}
+			seen[i] = true
+		}
+	}
+}
+
+func TestReservoirSampleUniform(t *testing.T) {
+	const (
+		rows   = 100
+		n      = 10
+		trials = 20000
+	)
+	rng := rand.New(rand.NewPCG(1, 2))
+	counts := make([]int, rows)
+	for range trials {
+		sample, err := ReservoirSample(InOrder(numbered(rows)), n, rng)
+		if err != nil {
+			t.Fatalf("ReservoirSample() error = %v", err)
+		}
+		for _, i := range drain(t, InOrder(SliceRows(sample))) {
+			counts[i]++
+		}
+	}
+
+	// Every row is picked with probability n/rows, 2000 times on average
+	for i, count := range counts {
+		if count < 1700 || count > 2300 {
+			t.Errorf("row %d sampled %d times, want about %d", i, count, trials*n/rows)
+		}
+	}
+}
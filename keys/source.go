@@ -0,0 +1,72 @@
+package keys
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Source yields input rows one at a time; Next returns io.EOF after the last row
+type Source interface {
+	Next() ([]string, error)
+}
+
+// CSVSource streams the rows of a CSV file without loading it into memory
+type CSVSource struct {
+	file   *os.File
+	reader *csv.Reader
+	header []string
+}
+
+// OpenCSV opens a CSV file for streaming; with header the first row is read
+// as the column names and not returned by Next
+func OpenCSV(path string, header bool) (*CSVSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	s := &CSVSource{file: file, reader: reader}
+	if header {
+		s.header, err = reader.Read()
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read header of %s: %w", path, err)
+		}
+	}
+	return s, nil
+}
+
+// Header returns the column names, nil without a header row
+func (s *CSVSource) Header() []string {
+	return s.header
+}
+
+// Next returns the next row
+func (s *CSVSource) Next() ([]string, error) {
+	return s.reader.Read()
+}
+
+// Close closes the underlying file
+func (s *CSVSource) Close() error {
+	return s.file.Close()
+}
+
+// ReadAll drains a source into memory
+func ReadAll(src Source) ([][]string, error) {
+	var rows [][]string
+	for {
+		row, err := src.Next()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
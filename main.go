@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"encoding/csv"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"io"
@@ -14,77 +14,262 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ParkerData/parkbench/auth"
 	"github.com/ParkerData/parkbench/config"
+	"github.com/ParkerData/parkbench/metrics"
 	parker_pb "github.com/ParkerData/parkbench/pb/parker_pb"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
+	"github.com/ParkerData/parkbench/report"
+	"github.com/ParkerData/parkbench/stats"
+	"github.com/ParkerData/parkbench/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/metadata"
 )
 
 func main() {
+	// Run the mock gateway instead of a benchmark
+	if len(os.Args) > 1 && os.Args[1] == "serve-mock" {
+		serveMock(os.Args[2:])
+		return
+	}
+
 	// Define CLI option for config file path and protocol
 	configPath := flag.String("config", "config.json", "Path to the configuration file")
 	useGRPC := flag.Bool("grpc", false, "Use gRPC protocol (default: HTTP)")
+	outputPath := flag.String("output", "", "Write the results as JSON to this file")
+	outputCSVPath := flag.String("output-csv", "", "Write the per-interval time series as CSV to this file")
+	metricsAddr := flag.String("metrics-addr", "", "Serve live Prometheus metrics on this address under /metrics")
 	flag.Parse()
 
+	protocol := "http"
+	if *useGRPC {
+		protocol = "grpc"
+	}
+
 	// Load configuration
 	cfg, err := config.LoadConfig(*configPath)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Create shared HTTP client if using HTTP
-	var httpClient *http.Client
-	if !*useGRPC {
-		httpClient = &http.Client{
-			Transport: &http.Transport{
-				MaxIdleConns:        1000,
-				MaxIdleConnsPerHost: 1000,
-				IdleConnTimeout:     90 * time.Second,
-				DisableKeepAlives:   false,
-			},
-			Timeout: 120 * time.Second,
+	// Seed the key order so runs can be reproduced and every target gets the same keys
+	if cfg.Seed == 0 {
+		cfg.Seed = rand.Uint64()
+	}
+	fmt.Printf("seed: %d\n", cfg.Seed)
+
+	// Export a span per request
+	shutdownTracing := func(context.Context) error { return nil }
+	if cfg.Tracing != nil {
+		shutdownTracing, err = tracing.Setup(context.Background(), cfg.Tracing)
+		if err != nil {
+			log.Fatalf("Failed to set up tracing: %v", err)
+		}
+	}
+	flushTracing := func() {
+		flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelFlush()
+		if err := shutdownTracing(flushCtx); err != nil {
+			log.Printf("Failed to flush traces: %v", err)
 		}
 	}
 
-	// Read the CSV file
-	file, err := os.Open(cfg.CSVFilePath)
-	if err != nil {
-		log.Fatalf("Failed to open CSV file: %v", err)
+	// Export live statistics to Prometheus
+	var metricsServer *metrics.Server
+	if *metricsAddr != "" {
+		metricsServer = metrics.NewServer()
+		go func() {
+			if err := metricsServer.Serve(*metricsAddr); err != nil {
+				log.Fatalf("Failed to serve metrics on %s: %v", *metricsAddr, err)
+			}
+		}()
+		fmt.Printf("metrics: http://%s/metrics\n", *metricsAddr)
+	}
+
+	if len(cfg.Targets) == 0 {
+		r := runBenchmark(cfg, "", *useGRPC, metricsServer)
+		flushTracing()
+		r.summary.WriteSummary(os.Stdout)
+
+		result := report.NewResult(protocol, cfg, r.intervals, r.summary)
+		if *outputPath != "" {
+			if err := report.WriteJSON(*outputPath, result); err != nil {
+				log.Fatalf("Failed to write results to %s: %v", *outputPath, err)
+			}
+		}
+		if *outputCSVPath != "" {
+			if err := report.WriteIntervalsCSV(*outputCSVPath, result.Intervals); err != nil {
+				log.Fatalf("Failed to write interval CSV to %s: %v", *outputCSVPath, err)
+			}
+		}
+		if r.budgetErr != nil {
+			log.Fatalf("Error budget exceeded: %v", r.budgetErr)
+		}
+		return
+	}
+
+	// Run the same workload against every target and compare them
+	runs := make([]*run, len(cfg.Targets))
+	var targetsDone sync.WaitGroup
+	for i, target := range cfg.Targets {
+		targetCfg, err := cfg.ForTarget(target)
+		if err != nil {
+			log.Fatalf("Failed to configure target: %v", err)
+		}
+		if cfg.ConcurrentTargets {
+			targetsDone.Add(1)
+			go func() {
+				defer targetsDone.Done()
+				runs[i] = runBenchmark(targetCfg, target.Name, *useGRPC, metricsServer)
+			}()
+			continue
+		}
+		fmt.Printf("\nTarget %s:\n", target.Name)
+		runs[i] = runBenchmark(targetCfg, target.Name, *useGRPC, metricsServer)
+		runs[i].summary.WriteSummary(os.Stdout)
+	}
+	targetsDone.Wait()
+	flushTracing()
+
+	comparison := &report.Comparison{Protocol: protocol, Concurrent: cfg.ConcurrentTargets}
+	var names []string
+	var summaries []stats.Snapshot
+	for _, r := range runs {
+		if cfg.ConcurrentTargets {
+			fmt.Printf("\nTarget %s:\n", r.name)
+			r.summary.WriteSummary(os.Stdout)
+		}
+		names = append(names, r.name)
+		summaries = append(summaries, r.summary)
+		comparison.Targets = append(comparison.Targets, report.TargetResult{
+			Name:   r.name,
+			Result: report.NewResult(protocol, r.cfg, r.intervals, r.summary),
+		})
+	}
+	stats.WriteComparison(os.Stdout, names, summaries)
+
+	if *outputPath != "" {
+		if err := report.WriteJSON(*outputPath, comparison); err != nil {
+			log.Fatalf("Failed to write results to %s: %v", *outputPath, err)
+		}
+	}
+	if *outputCSVPath != "" {
+		if err := report.WriteComparisonCSV(*outputCSVPath, comparison); err != nil {
+			log.Fatalf("Failed to write interval CSV to %s: %v", *outputCSVPath, err)
+		}
+	}
+	for _, r := range runs {
+		if r.budgetErr != nil {
+			log.Fatalf("Error budget exceeded for target %s: %v", r.name, r.budgetErr)
+		}
+	}
+}
+
+// run holds the outcome of the benchmark against one target
+type run struct {
+	name      string
+	cfg       *config.Config
+	intervals []stats.Snapshot
+	summary   stats.Snapshot
+	budgetErr error
+}
+
+// runBenchmark sends the workload of cfg until it is done; name is the
+// target of a comparison, "" for a single run, and prefixes the live output
+func runBenchmark(cfg *config.Config, name string, useGRPC bool, metricsServer *metrics.Server) *run {
+	protocol := "http"
+	if useGRPC {
+		protocol = "grpc"
+	}
+	prefix := ""
+	if name != "" {
+		prefix = "[" + name + "] "
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	// Set up the clients of the servers to send to
+	targets, err := newBalancer(cfg, useGRPC)
 	if err != nil {
-		log.Fatalf("Failed to read CSV file: %v", err)
+		log.Fatalf("Failed to set up %s clients: %v", protocolName(useGRPC), err)
+	}
+	defer targets.Close()
+	if !useGRPC {
+		fmt.Printf("%shttp version: %s\n", prefix, cfg.HTTPVersion)
+	}
+	if len(targets.endpoints) > 1 {
+		fmt.Printf("%sendpoints: %d, %s\n", prefix, len(targets.endpoints), cfg.Balance)
 	}
 
-	println("input csv rows:", len(records))
+	rng := rand.New(rand.NewPCG(cfg.Seed, cfg.Seed))
 
-	// Channel to distribute IDs to workers
-	idChan := make(chan string, 10000)
-	go func() {
-		for x := 0; x < cfg.RepeatTimes; x++ {
-			// Randomize the order of IDs
-			total := len(records)
-			for i := range records {
-				j := rand.IntN(total)
-				records[i], records[j] = records[j], records[i]
-			}
+	// Open the CSV file and work out its layout
+	in, err := openInput(cfg, rng)
+	if err != nil {
+		log.Fatalf("Failed to open CSV file: %v", err)
+	}
+	columns, err := inputColumns(cfg, in.header)
+	if err != nil {
+		log.Fatalf("Invalid CSV layout: %v", err)
+	}
+	parser := newRowParser(cfg, columns)
+
+	// Context to end a timed run and to abort once the error budget is exceeded
+	ctx, abort := context.WithCancel(context.Background())
+	defer abort()
+	if cfg.Duration.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Warmup.Duration+cfg.Duration.Duration+cfg.Cooldown.Duration)
+		defer cancel()
+	}
 
-			for _, record := range records {
-				idChan <- record[0]
-			}
+	// Channel to distribute IDs to workers, cycling the CSV until a timed run ends
+	idChan := make(chan request, 10000)
+	go func() {
+		if err := in.produce(ctx, parser, idChan); err != nil {
+			log.Fatalf("%v", err)
 		}
-		close(idChan)
 	}()
 
+	// Pace requests at the target rate in open-loop mode
+	requestChan := idChan
+	if cfg.Rate > 0 {
+		pacedChan := make(chan request, 10000)
+		go paceRequests(ctx, idChan, pacedChan, cfg.Rate, cfg.Arrival)
+		requestChan = pacedChan
+		fmt.Printf("%sopen-loop mode: %.2f requests per second, %s arrivals\n", prefix, cfg.Rate, cfg.Arrival)
+	}
+
 	// WaitGroup to wait for all workers to finish
 	var wg sync.WaitGroup
 
-	// Channel to collect latencies
-	latencyChan := make(chan time.Duration, 10000)
+	// Recorder to collect latencies
+	recorder := stats.NewRecorder()
+	recorder.SetRecordTimeouts(cfg.RecordTimeouts)
+	if cfg.Warmup.Duration > 0 {
+		recorder.SetPhase(stats.PhaseWarmup)
+	}
+	go runPhases(ctx, cfg, recorder)
+
+	// Export live statistics to Prometheus
+	var liveMetrics *metrics.Metrics
+	if metricsServer != nil {
+		liveMetrics = metricsServer.New(protocol, name, cfg.Concurrency, cfg.Rate, func() float64 { return float64(recorder.InFlight()) })
+		recorder.SetObserver(liveMetrics)
+	}
+
+	// Bearer tokens shared by the workers, the first fetched before any
+	// request so its fetch is not measured
+	tokens := auth.NewProvider(cfg)
+	if _, err := tokens.Token(ctx); err != nil {
+		log.Fatalf("Failed to obtain a bearer token: %v", err)
+	}
+
+	if useGRPC && cfg.GRPC.Connections > 0 {
+		fmt.Printf("%sgRPC connections: %d per endpoint shared by %d workers\n", prefix, cfg.GRPC.Connections, cfg.Concurrency)
+	}
+
+	// Hedge late requests if configured
+	hedges := newHedger(cfg.Hedge)
 
 	// Start workers
 	for i := 0; i < cfg.Concurrency; i++ {
@@ -92,39 +277,67 @@ func main() {
 		go func() {
 			defer wg.Done()
 
-			if *useGRPC {
-				if cfg.GRPCServerAddress == "" {
-					log.Fatalf("gRPC server address not provided in config")
-				}
-				grpcQueryJob(cfg, idChan, latencyChan)
+			if useGRPC {
+				grpcQueryJob(ctx, cfg, targets, hedges, tokens, requestChan, recorder)
 			} else {
-				if cfg.HTTPServerAddress == "" {
-					log.Fatalf("HTTP server address not provided in config")
-				}
-				httpQueryJob(httpClient, cfg.HTTPServerAddress, cfg.JWTString, idChan, latencyChan, cfg)
+				httpQueryJob(ctx, targets, hedges, tokens, requestChan, recorder, cfg)
 			}
 		}()
 	}
 
 	// Monitor and print latency and requests per second
+	var budgetErr error
+	var intervals []stats.Snapshot
+	done := make(chan struct{})
+	monitorDone := make(chan struct{})
 	go func() {
+		defer close(monitorDone)
 		ticker := time.NewTicker(time.Second)
 		defer ticker.Stop()
 
-		var totalRequests int
-		var totalLatency time.Duration
-
 		for {
 			select {
-			case latency := <-latencyChan:
-				totalRequests++
-				totalLatency += latency
+			case <-done:
+				// Keep the partial interval since the last tick in the time series
+				intervals = append(intervals, recorder.Interval())
+				return
 			case <-ticker.C:
-				if totalRequests > 0 {
-					avgLatency := totalLatency / time.Duration(totalRequests)
-					fmt.Printf("Requests per second: %d, Average latency: %v\n", totalRequests, avgLatency)
-					totalRequests = 0
-					totalLatency = 0
+				interval := recorder.Interval()
+				intervals = append(intervals, interval)
+				if liveMetrics != nil {
+					liveMetrics.SetAchievedRate(interval.Throughput())
+				}
+				if interval.Completed() > 0 {
+					line := fmt.Sprintf("Requests per second: %d, Average latency: %v", interval.Successful(), interval.Mean)
+					if interval.Unhedged != nil {
+						line = fmt.Sprintf("Requests per second: %d, Average hedged latency: %v, Average unhedged latency: %v", interval.Successful(), interval.Mean, interval.Unhedged.Mean)
+					}
+					if interval.Misses != nil {
+						line += fmt.Sprintf(", Misses: %d, Average miss latency: %v", interval.Misses.Requests, interval.Misses.Mean)
+					}
+					if interval.Phase != stats.PhaseMeasure {
+						line = fmt.Sprintf("[%s] %s", interval.Phase, line)
+					}
+					if errors := interval.ErrorCount(); errors > 0 {
+						line += fmt.Sprintf(", Errors: %d (%s)", errors, interval.FormatErrors())
+					}
+					if retries := interval.RetryCount(); retries > 0 {
+						line += fmt.Sprintf(", Retries: %d", retries)
+					}
+					if interval.Hedges != nil {
+						line += fmt.Sprintf(", Hedged: %.2f%%", interval.Hedges.HedgeRate()*100)
+					}
+					fmt.Println(prefix + line)
+				}
+
+				if !hasErrorBudget(cfg) {
+					continue
+				}
+				if err := checkErrorBudget(cfg, recorder.Counts()); err != nil {
+					budgetErr = err
+					log.Printf("%sAborting run: %v", prefix, err)
+					abort()
+					return
 				}
 			}
 		}
@@ -132,95 +345,312 @@ func main() {
 
 	// Wait for all workers to finish
 	wg.Wait()
-	close(latencyChan)
+	close(done)
+	<-monitorDone
+	// Stop the summary clock only now, so the final partial interval keeps
+	// the phase its samples were measured in
+	recorder.SetPhase(stats.PhaseCooldown)
+
+	summary := recorder.Summary()
+	if budgetErr == nil {
+		budgetErr = checkErrorBudget(cfg, summary)
+	}
+	return &run{name: name, cfg: cfg, intervals: intervals, summary: summary, budgetErr: budgetErr}
+}
+
+// runPhases moves the recorder from warmup to measurement to cooldown on schedule
+func runPhases(ctx context.Context, cfg *config.Config, recorder *stats.Recorder) {
+	if cfg.Warmup.Duration > 0 {
+		select {
+		case <-time.After(cfg.Warmup.Duration):
+		case <-ctx.Done():
+			return
+		}
+		recorder.SetPhase(stats.PhaseMeasure)
+	}
+
+	if cfg.Duration.Duration > 0 {
+		select {
+		case <-time.After(cfg.Duration.Duration):
+		case <-ctx.Done():
+			return
+		}
+		recorder.SetPhase(stats.PhaseCooldown)
+	}
+}
+
+// httpTimer collects the httptrace events of one request; dials may report
+// from other goroutines, hence the lock
+type httpTimer struct {
+	mu           sync.Mutex
+	getConn      time.Time
+	gotConn      time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	reused       bool
+	wasIdle      bool
 }
 
-func tracedRequestContext() context.Context {
-	return httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+// tracedRequestContext times the connection and response stages of an HTTP request into timer
+func tracedRequestContext(ctx context.Context, timer *httpTimer) context.Context {
+	at := func(t *time.Time) {
+		timer.mu.Lock()
+		*t = time.Now()
+		timer.mu.Unlock()
+	}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) { at(&timer.getConn) },
 		GotConn: func(info httptrace.GotConnInfo) {
-			// fmt.Println("🧪 Connection info:")
-			// fmt.Println("  Reused:  ", info.Reused)
-			// fmt.Println("  WasIdle:", info.WasIdle)
-			// fmt.Println("  IdleTime:", info.IdleTime)
+			at(&timer.gotConn)
+			timer.mu.Lock()
+			timer.reused = info.Reused
+			timer.wasIdle = info.WasIdle
+			timer.mu.Unlock()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) { at(&timer.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { at(&timer.dnsDone) },
+		ConnectStart: func(string, string) {
+			// Keep the first of several dial attempts
+			timer.mu.Lock()
+			if timer.connectStart.IsZero() {
+				timer.connectStart = time.Now()
+			}
+			timer.mu.Unlock()
 		},
+		ConnectDone:          func(string, string, error) { at(&timer.connectDone) },
+		TLSHandshakeStart:    func() { at(&timer.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { at(&timer.tlsDone) },
+		GotFirstResponseByte: func() { at(&timer.firstByte) },
 	})
 }
 
-func httpQueryJob(httpClient *http.Client, httpServerAddress string, jwtString string, idChan chan string, latencyChan chan time.Duration, cfg *config.Config) {
-	count := 0
-	for id := range idChan {
-		start := time.Now()
+// timing returns the stage durations of a request whose body was read at end
+func (t *httpTimer) timing(end time.Time) stats.HTTPTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-		targetUrl := fmt.Sprintf("%s/find/%s/%s/%s", httpServerAddress, cfg.AccountName, cfg.TableName, id)
-		req, err := http.NewRequestWithContext(tracedRequestContext(), http.MethodGet, targetUrl, nil)
-		if err != nil {
-			log.Fatalf("Failed to create HTTP request to %v: %v", targetUrl, err)
+	timing := stats.HTTPTiming{Stages: make(map[string]time.Duration), Reused: t.reused, WasIdle: t.wasIdle}
+	stage := func(name string, from, to time.Time) {
+		if !from.IsZero() && !to.IsZero() && !to.Before(from) {
+			timing.Stages[name] = to.Sub(from)
 		}
+	}
+	stage(stats.StageConnWait, t.getConn, t.gotConn)
+	stage(stats.StageDNS, t.dnsStart, t.dnsDone)
+	stage(stats.StageConnect, t.connectStart, t.connectDone)
+	stage(stats.StageTLS, t.tlsStart, t.tlsDone)
+	stage(stats.StageFirstByte, t.gotConn, t.firstByte)
+	stage(stats.StageTransfer, t.firstByte, end)
+	return timing
+}
 
-		if jwtString != "" {
-			req.Header["Authorization"] = []string{"Bearer " + jwtString}
+func httpQueryJob(ctx context.Context, targets *balancer, hedges *hedger, tokens auth.TokenProvider, idChan chan request, recorder *stats.Recorder, cfg *config.Config) {
+	retries := newRetrier(cfg.Retry)
+	count := 0
+	for req := range idChan {
+		if ctx.Err() != nil {
+			return
 		}
-		req.Close = false
+		start := req.startTime()
+		req.unhedged = hedges.control()
 
 		count++
-		// fmt.Printf("%d: Resolved URL: %s\n", count, targetUrl)
-		resp, err := httpClient.Do(req)
-		if err != nil {
-			log.Fatalf("Failed to send HTTP request to %v: %v", targetUrl, err)
-		}
+		spanCtx, span := tracing.Start(context.Background(), "GET /find", start, requestAttributes(req, "http")...)
+		latency, class, err := retries.do(ctx, span, recorder, func() (time.Duration, string, error) {
+			return hedges.send(spanCtx, span, recorder, req.unhedged, func(sendCtx context.Context, hedge bool) (time.Duration, string, error) {
+				target := targets.pick()
+				if hedge {
+					target = targets.pickHedge()
+				}
+				targetUrl := findURL(cfg, target.httpAddress, req)
+				// fmt.Printf("%d: Resolved URL: %s\n", count, targetUrl)
+				span.SetAttributes(attribute.String("url.full", targetUrl))
+				return targets.send(sendCtx, target, recorder, func() (time.Duration, string, error) {
+					return httpFind(sendCtx, target.httpClient, targetUrl, cfg.RequestTimeout.Duration, tokens, req, start, recorder)
+				})
+			})
+		})
+		endSpan(span, req, recordOutcome(recorder, req, latency, class, err), err)
+	}
+}
 
-		if resp.StatusCode != http.StatusOK {
-			log.Fatalf("Failed to get a successful response from %v: %v", targetUrl, resp.Status)
-		}
+// findURL returns the URL of the HTTP Find endpoint for a request to address
+func findURL(cfg *config.Config, address string, req request) string {
+	path, keyType := keyPath(req.key)
+	return fmt.Sprintf("%s/find/%s/%s/%s%s", address, cfg.AccountName, cfg.TableName, path, req.params.query(keyType))
+}
 
-		// read the response body
-		io.Copy(io.Discard, resp.Body)
-		resp.Body.Close()
+// httpFind sends one HTTP request and returns its latency since start, or the
+// error class and error of a failed request; latency is also set for misses
+func httpFind(ctx context.Context, httpClient *http.Client, targetUrl string, timeout time.Duration, tokens auth.TokenProvider, req request, start time.Time, recorder *stats.Recorder) (time.Duration, string, error) {
+	token, err := tokens.Token(ctx)
+	if err != nil {
+		return 0, stats.ErrorAuthToken, fmt.Errorf("failed to get token: %w", err)
+	}
 
-		latency := time.Since(start)
-		latencyChan <- latency
+	// The deadline covers sending the request and reading the whole response
+	requestCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	timer := &httpTimer{}
+	httpReq, err := http.NewRequestWithContext(tracedRequestContext(tracing.WithClientTrace(requestCtx), timer), http.MethodGet, targetUrl, nil)
+	if err != nil {
+		log.Fatalf("Failed to create HTTP request to %v: %v", targetUrl, err)
 	}
-}
+	if token != "" {
+		httpReq.Header["Authorization"] = []string{"Bearer " + token}
+	}
+	tracing.InjectHTTP(ctx, httpReq.Header)
+	httpReq.Close = false
 
-func grpcQueryJob(cfg *config.Config, idChan chan string, latencyChan chan time.Duration) {
-	// Set up a secure gRPC client using TLS
-	creds := credentials.NewClientTLSFromCert(nil, "") // nil means use system's trusted CAs
+	span := trace.SpanFromContext(ctx)
+	span.AddEvent("sent")
+	recorder.Begin()
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recorder.End()
+		return time.Since(start), classifyTransportError(err), fmt.Errorf("failed to send HTTP request to %v: %w", targetUrl, err)
+	}
 
-	// Set up a gRPC client
-	conn, err := grpc.NewClient(cfg.GRPCServerAddress, grpc.WithTransportCredentials(creds))
+	// read the response body, keeping it only to validate the record
+	var body []byte
+	if len(req.expected) > 0 {
+		body, err = io.ReadAll(resp.Body)
+	} else {
+		_, err = io.Copy(io.Discard, resp.Body)
+	}
+	resp.Body.Close()
+	recorder.End()
+	timing := timer.timing(time.Now())
+	timing.Protocol = resp.Proto
+	recorder.RecordHTTP(timing)
+	span.AddEvent("body_read")
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
+	// Validation happens after the latency is taken so it is not measured
+	latency := time.Since(start)
+	if resp.StatusCode != http.StatusOK {
+		class := classifyHTTPStatus(resp.StatusCode)
+		if stats.IsAuthFailure(class) {
+			tokens.Invalidate(token)
+		}
+		return latency, class, fmt.Errorf("failed to get a successful response from %v: %v", targetUrl, resp.Status)
+	}
 	if err != nil {
-		log.Fatalf("Failed to connect to gRPC server: %v", err)
+		return latency, classifyTransportError(err), fmt.Errorf("failed to read HTTP response from %v: %w", targetUrl, err)
+	}
+	if len(req.expected) > 0 {
+		if class, err := validateHTTPBody(body, req.expected); err != nil {
+			return 0, class, fmt.Errorf("invalid response from %v: %w", targetUrl, err)
+		}
 	}
-	defer conn.Close()
+	return latency, "", nil
+}
 
-	client := parker_pb.NewGatewayClient(conn)
+// grpcQueryJob sends requests over the shared connections of the endpoints,
+// or over a connection of its own to a single server without them
+func grpcQueryJob(ctx context.Context, cfg *config.Config, targets *balancer, hedges *hedger, tokens auth.TokenProvider, idChan chan request, recorder *stats.Recorder) {
+	var own *grpcPool
+	if targets.endpoints[0].grpc == nil {
+		var err error
+		own, err = newGRPCPool(cfg, targets.endpoints[0].grpcAddress, 1)
+		if err != nil {
+			log.Fatalf("Failed to connect to gRPC server: %v", err)
+		}
+		defer own.Close()
+	}
 
-	ctx := context.Background()
-	if cfg.JWTString != "" {
-		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+cfg.JWTString)
+	retries := newRetrier(cfg.Retry)
+	for req := range idChan {
+		if ctx.Err() != nil {
+			return
+		}
+		start := req.startTime()
+		req.unhedged = hedges.control()
+
+		spanCtx, span := tracing.Start(context.Background(), parker_pb.Gateway_Find_FullMethodName, start, requestAttributes(req, "grpc")...)
+		latency, class, err := retries.do(ctx, span, recorder, func() (time.Duration, string, error) {
+			return hedges.send(spanCtx, span, recorder, req.unhedged, func(sendCtx context.Context, hedge bool) (time.Duration, string, error) {
+				target := targets.pick()
+				if hedge {
+					target = targets.pickHedge()
+				}
+				return targets.send(sendCtx, target, recorder, func() (time.Duration, string, error) {
+					return grpcFind(sendCtx, target.client(own), cfg, tokens, req, start, recorder)
+				})
+			})
+		})
+		endSpan(span, req, recordOutcome(recorder, req, latency, class, err), err)
 	}
+}
 
-	for id := range idChan {
-		start := time.Now()
+// grpcFind calls Find for one request and returns its latency since start, or
+// the error class and error of a failed call; latency is also set for misses
+func grpcFind(ctx context.Context, client parker_pb.GatewayClient, cfg *config.Config, tokens auth.TokenProvider, req request, start time.Time, recorder *stats.Recorder) (time.Duration, string, error) {
+	token, err := tokens.Token(ctx)
+	if err != nil {
+		return 0, stats.ErrorAuthToken, fmt.Errorf("failed to get token: %w", err)
+	}
+	if token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
 
-		// Create a FindRequest
-		request := &parker_pb.FindRequest{
-			Account: cfg.AccountName,
-			Table:   cfg.TableName,
-			Key: &parker_pb.Key{
-				Kind: &parker_pb.Key_StringValue{
-					StringValue: id,
-				},
-			},
+	// Create a FindRequest
+	request := req.params.findRequest(cfg, req.key)
+
+	// Call the Find method
+	ctx, cancel := context.WithTimeout(ctx, cfg.RequestTimeout.Duration)
+	defer cancel()
+	trace.SpanFromContext(ctx).AddEvent("sent")
+	recorder.Begin()
+	resp, err := client.Find(tracing.InjectGRPC(ctx), request)
+	recorder.End()
+	latency := time.Since(start)
+	if err != nil {
+		class := classifyGRPCError(err)
+		if stats.IsAuthFailure(class) {
+			tokens.Invalidate(token)
 		}
+		return latency, class, fmt.Errorf("failed to call Find: %w", err)
+	}
+	if len(req.expected) > 0 {
+		if class, err := validateRecord(resp.GetRecord(), req.expected); err != nil {
+			return 0, class, fmt.Errorf("invalid response for key %v: %w", req.key, err)
+		}
+	}
+	return latency, "", nil
+}
 
-		// Call the Find method
-		_, err := client.Find(ctx, request)
+// requestAttributes describes a request on its span
+func requestAttributes(req request, protocol string) []attribute.KeyValue {
+	key, keyType := keyPath(req.key)
+	return []attribute.KeyValue{
+		attribute.String("parkbench.protocol", protocol),
+		attribute.String("parkbench.key", key),
+		attribute.String("parkbench.key_type", keyType),
+		attribute.Bool("parkbench.expect_miss", req.expectMiss),
+		attribute.Bool("parkbench.paced", !req.intended.IsZero()),
+	}
+}
+
+// endSpan records the outcome of a request on its span; expected misses are not errors
+func endSpan(span trace.Span, req request, class string, err error) {
+	switch {
+	case class == "":
+		span.SetAttributes(attribute.String("parkbench.result", "ok"))
+	case class == stats.ErrorMiss && req.expectMiss:
+		span.SetAttributes(attribute.String("parkbench.result", class))
+	default:
+		span.SetAttributes(attribute.String("parkbench.result", class))
+		description := class
 		if err != nil {
-			log.Fatalf("Failed to call Find: %v", err)
+			description = err.Error()
 		}
-
-		latency := time.Since(start)
-		latencyChan <- latency
+		span.SetStatus(codes.Error, description)
 	}
+	span.End()
 }
@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Request statuses besides the error classes
+const (
+	StatusOK   = "ok"
+	StatusMiss = "miss"
+)
+
+// Server exposes the live statistics of the runs of an invocation in the
+// Prometheus format so client-side views can be overlaid with the server dashboards
+type Server struct {
+	registry *prometheus.Registry
+}
+
+// NewServer creates a server without any runs yet
+func NewServer() *Server {
+	return &Server{registry: prometheus.NewRegistry()}
+}
+
+// Serve exposes the metrics on addr under /metrics until the server fails
+func (s *Server) Serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{}))
+	return http.ListenAndServe(addr, mux)
+}
+
+// Metrics holds the live statistics of one run
+type Metrics struct {
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	achieved prometheus.Gauge
+}
+
+// New registers the metrics of a run over protocol with workers workers;
+// target labels the run of a comparison and is "" for a single run,
+// offeredRate is the open-loop target rate, 0 in closed-loop mode, and
+// inFlight reports the requests currently waiting for a response
+func (s *Server) New(protocol, target string, workers int, offeredRate float64, inFlight func() float64) *Metrics {
+	labels := prometheus.Labels{"protocol": protocol}
+	if target != "" {
+		labels["target"] = target
+	}
+	factory := prometheus.WrapRegistererWith(labels, s.registry)
+
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "parkbench_requests_total",
+			Help: "Completed requests by status: ok, miss for expected misses, or the error class.",
+		}, []string{"status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "parkbench_request_duration_seconds",
+			Help:    "Latency of successful requests, measured from the intended send time in open-loop mode.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 2, 18),
+		}, []string{"status"}),
+		achieved: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "parkbench_achieved_rate",
+			Help: "Requests completed per second over the last reporting interval.",
+		}),
+	}
+	factory.MustRegister(
+		m.requests,
+		m.latency,
+		m.achieved,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "parkbench_in_flight_requests",
+			Help: "Requests sent and waiting for a response.",
+		}, inFlight),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "parkbench_workers",
+			Help: "Number of concurrent workers.",
+		}, func() float64 { return float64(workers) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "parkbench_offered_rate",
+			Help: "Target requests per second in open-loop mode, 0 in closed-loop mode.",
+		}, func() float64 { return offeredRate }),
+	)
+	return m
+}
+
+// ObserveSuccess counts a successful request and its latency
+func (m *Metrics) ObserveSuccess(latency time.Duration) {
+	m.requests.WithLabelValues(StatusOK).Inc()
+	m.latency.WithLabelValues(StatusOK).Observe(latency.Seconds())
+}
+
+// ObserveMiss counts an expected miss and its latency
+func (m *Metrics) ObserveMiss(latency time.Duration) {
+	m.requests.WithLabelValues(StatusMiss).Inc()
+	m.latency.WithLabelValues(StatusMiss).Observe(latency.Seconds())
+}
+
+// ObserveError counts a failed request under its error class
+func (m *Metrics) ObserveError(class string) {
+	m.requests.WithLabelValues(class).Inc()
+}
+
+// SetAchievedRate publishes the throughput of the last reporting interval
+func (m *Metrics) SetAchievedRate(rate float64) {
+	m.achieved.Set(rate)
+}
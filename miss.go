@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand/v2"
+
+	"github.com/ParkerData/parkbench/config"
+	"github.com/ParkerData/parkbench/keys"
+	parker_pb "github.com/ParkerData/parkbench/pb/parker_pb"
+)
+
+// missPrefix marks the generated string and bytes keys expected to be missing
+const missPrefix = "__parkbench_miss_"
+
+// missKeys picks the keys expected to be missing from the table, either from
+// the missCsv file or generated so they cannot collide with real keys
+type missKeys struct {
+	cfg      *config.Config
+	rng      *rand.Rand
+	params   *findParams
+	fraction float64
+	// keys holds the parsed missCsv rows; nil to generate the keys
+	keys []*parker_pb.Key
+}
+
+// newMissKeys loads the missCsv file if set; it returns nil when no misses are mixed in
+func newMissKeys(cfg *config.Config, rng *rand.Rand) (*missKeys, error) {
+	if cfg.MissFraction == 0 {
+		return nil, nil
+	}
+
+	m := &missKeys{cfg: cfg, rng: rng, params: newFindParams(cfg), fraction: cfg.MissFraction}
+	if cfg.MissCSV == "" {
+		return m, nil
+	}
+
+	src, err := keys.OpenCSV(cfg.MissCSV, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open miss CSV file: %w", err)
+	}
+	defer src.Close()
+	for {
+		record, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read miss CSV file: %w", err)
+		}
+		key, err := parseKey(record[0], cfg.KeyType, cfg.KeyEncoding)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse miss CSV row %d: %w", len(m.keys)+1, err)
+		}
+		m.keys = append(m.keys, key)
+	}
+	if len(m.keys) == 0 {
+		return nil, fmt.Errorf("miss CSV file %s has no rows", cfg.MissCSV)
+	}
+	println("miss csv rows:", len(m.keys))
+	return m, nil
+}
+
+// next returns a request for a key expected to be missing
+func (m *missKeys) next() request {
+	return request{key: m.key(), params: m.params, expectMiss: true}
+}
+
+func (m *missKeys) key() *parker_pb.Key {
+	if m.keys != nil {
+		return m.keys[m.rng.IntN(len(m.keys))]
+	}
+
+	// Integer keys are assumed to be non-negative, so negative ones miss
+	switch m.cfg.KeyType {
+	case config.KeyInt32:
+		return &parker_pb.Key{Kind: &parker_pb.Key_Int32Value{Int32Value: -1 - m.rng.Int32N(1<<30)}}
+	case config.KeyInt64:
+		return &parker_pb.Key{Kind: &parker_pb.Key_Int64Value{Int64Value: -1 - m.rng.Int64N(1<<62)}}
+	}
+
+	suffix := make([]byte, 8)
+	for i := range suffix {
+		suffix[i] = byte(m.rng.Uint32())
+	}
+	if m.cfg.KeyType == config.KeyBytes {
+		return &parker_pb.Key{Kind: &parker_pb.Key_BytesValue{BytesValue: append([]byte(missPrefix), suffix...)}}
+	}
+	return &parker_pb.Key{Kind: &parker_pb.Key_StringValue{StringValue: missPrefix + hex.EncodeToString(suffix)}}
+}
+
+// send delivers req to out after the misses drawn for it, so that misses make
+// up the configured fraction of all requests; it returns false once ctx ends
+func (m *missKeys) send(ctx context.Context, out chan<- request, req request) bool {
+	for m != nil && m.rng.Float64() < m.fraction {
+		select {
+		case out <- m.next():
+		case <-ctx.Done():
+			return false
+		}
+	}
+	select {
+	case out <- req:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,99 @@
+package mock
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Latency draws the delay injected before each response
+type Latency interface {
+	Next() time.Duration
+}
+
+// ParseLatency parses a latency distribution such as "fixed:2ms",
+// "uniform:1ms-5ms", "normal:5ms,1ms" (mean, stddev) or "exponential:2ms" (mean)
+func ParseLatency(spec string) (Latency, error) {
+	if spec == "" {
+		return fixedLatency(0), nil
+	}
+
+	kind, args, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "fixed":
+		d, err := time.ParseDuration(args)
+		if err != nil {
+			return nil, err
+		}
+		return fixedLatency(d), nil
+	case "uniform":
+		low, high, err := parseDurationPair(args, "-")
+		if err != nil {
+			return nil, err
+		}
+		if high < low {
+			return nil, fmt.Errorf("uniform latency maximum %v is below minimum %v", high, low)
+		}
+		return uniformLatency{min: low, max: high}, nil
+	case "normal":
+		mean, stddev, err := parseDurationPair(args, ",")
+		if err != nil {
+			return nil, err
+		}
+		return normalLatency{mean: mean, stddev: stddev}, nil
+	case "exponential":
+		mean, err := time.ParseDuration(args)
+		if err != nil {
+			return nil, err
+		}
+		return exponentialLatency{mean: mean}, nil
+	}
+	return nil, fmt.Errorf("unknown latency distribution %q", kind)
+}
+
+func parseDurationPair(s string, sep string) (time.Duration, time.Duration, error) {
+	first, second, ok := strings.Cut(s, sep)
+	if !ok {
+		return 0, 0, fmt.Errorf("expected two durations separated by %q in %q", sep, s)
+	}
+	a, err := time.ParseDuration(first)
+	if err != nil {
+		return 0, 0, err
+	}
+	b, err := time.ParseDuration(second)
+	if err != nil {
+		return 0, 0, err
+	}
+	return a, b, nil
+}
+
+type fixedLatency time.Duration
+
+func (l fixedLatency) Next() time.Duration {
+	return time.Duration(l)
+}
+
+type uniformLatency struct {
+	min, max time.Duration
+}
+
+func (l uniformLatency) Next() time.Duration {
+	return l.min + time.Duration(rand.Int64N(int64(l.max-l.min)+1))
+}
+
+type normalLatency struct {
+	mean, stddev time.Duration
+}
+
+func (l normalLatency) Next() time.Duration {
+	return max(0, l.mean+time.Duration(rand.NormFloat64()*float64(l.stddev)))
+}
+
+type exponentialLatency struct {
+	mean time.Duration
+}
+
+func (l exponentialLatency) Next() time.Duration {
+	return time.Duration(rand.ExpFloat64() * float64(l.mean))
+}
@@ -0,0 +1,280 @@
+package mock
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	parker_pb "github.com/ParkerData/parkbench/pb/parker_pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Options configures the behavior of the mock gateway
+type Options struct {
+	// Table holds the records to serve; when nil every key is found
+	Table *Table
+	// Latency is injected before every response
+	Latency Latency
+	// ErrorRate is the fraction of requests answered with ErrorStatus over
+	// HTTP and UNAVAILABLE over gRPC
+	ErrorRate   float64
+	ErrorStatus int
+	// ResponseSize adds a bytes column of this many bytes to every record
+	ResponseSize int
+}
+
+// Server is a Parker gateway serving Find over gRPC and HTTP from memory
+type Server struct {
+	parker_pb.UnimplementedGatewayServer
+	options Options
+	padding *parker_pb.Value
+	mux     *http.ServeMux
+}
+
+// NewServer creates a mock gateway
+func NewServer(options Options) *Server {
+	if options.Latency == nil {
+		options.Latency = fixedLatency(0)
+	}
+	if options.ErrorStatus == 0 {
+		options.ErrorStatus = http.StatusServiceUnavailable
+	}
+
+	s := &Server{options: options}
+	if options.ResponseSize > 0 {
+		s.padding = &parker_pb.Value{Kind: &parker_pb.Value_BytesValue{BytesValue: make([]byte, options.ResponseSize)}}
+	}
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("GET /find/{account}/{table}/{key}", s.handleFind)
+	return s
+}
+
+// Find implements the gRPC Gateway service
+func (s *Server) Find(ctx context.Context, req *parker_pb.FindRequest) (*parker_pb.FindResponse, error) {
+	return s.find(ctx, req)
+}
+
+// ServeHTTP implements the HTTP find endpoint
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleFind(w http.ResponseWriter, r *http.Request) {
+	req, err := parseFindRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := s.find(r.Context(), req)
+	if err != nil {
+		switch status.Code(err) {
+		case codes.NotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case codes.InvalidArgument:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case codes.Unavailable:
+			http.Error(w, err.Error(), s.options.ErrorStatus)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	body, err := protojson.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// partitionParam prefixes the query parameters carrying a partition value,
+// the rest of the parameter name being the partition key
+const partitionParam = "partition."
+
+// parseFindRequest reads the FindRequest of the HTTP find endpoint: the key
+// from the path, typed by the key_type parameter, and the optional fields
+// from the query parameters partition.<key>, key_column, snapshot and
+// columns (repeated). Unknown or repeated parameters are rejected so a
+// client sending something the gateway would ignore finds out
+func parseFindRequest(r *http.Request) (*parker_pb.FindRequest, error) {
+	query := r.URL.Query()
+	key, err := parseKey(r.PathValue("key"), query.Get("key_type"))
+	if err != nil {
+		return nil, err
+	}
+	req := &parker_pb.FindRequest{
+		Account: r.PathValue("account"),
+		Table:   r.PathValue("table"),
+		Key:     key,
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(query)) {
+		values := query[name]
+		if len(values) > 1 && name != "columns" {
+			return nil, fmt.Errorf("query parameter %q given %d times", name, len(values))
+		}
+		switch {
+		case name == "key_type":
+		case name == "key_column":
+			req.KeyColumn = values[0]
+		case name == "snapshot":
+			if req.Snapshot, err = strconv.ParseInt(values[0], 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid snapshot %q", values[0])
+			}
+		case name == "columns":
+			req.Columns = values
+		case strings.HasPrefix(name, partitionParam) && len(name) > len(partitionParam):
+			req.Partitions = append(req.Partitions, &parker_pb.Partition{
+				PartitionKey:   strings.TrimPrefix(name, partitionParam),
+				PartitionValue: values[0],
+			})
+		default:
+			return nil, fmt.Errorf("unknown query parameter %q", name)
+		}
+	}
+	return req, nil
+}
+
+// parseKey converts the key of an HTTP path into the Key variant of keyType:
+// integers are decimal and bytes hex encoded
+func parseKey(key string, keyType string) (*parker_pb.Key, error) {
+	switch keyType {
+	case "", "string":
+		return &parker_pb.Key{Kind: &parker_pb.Key_StringValue{StringValue: key}}, nil
+	case "int32":
+		v, err := strconv.ParseInt(key, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int32 key %q", key)
+		}
+		return &parker_pb.Key{Kind: &parker_pb.Key_Int32Value{Int32Value: int32(v)}}, nil
+	case "int64":
+		v, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int64 key %q", key)
+		}
+		return &parker_pb.Key{Kind: &parker_pb.Key_Int64Value{Int64Value: v}}, nil
+	case "bytes":
+		v, err := hex.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex bytes key %q", key)
+		}
+		return &parker_pb.Key{Kind: &parker_pb.Key_BytesValue{BytesValue: v}}, nil
+	}
+	return nil, fmt.Errorf("unknown key_type %q", keyType)
+}
+
+// find looks up a key after the injected latency and errors
+func (s *Server) find(ctx context.Context, req *parker_pb.FindRequest) (*parker_pb.FindResponse, error) {
+	if delay := s.options.Latency.Next(); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	if s.options.ErrorRate > 0 && rand.Float64() < s.options.ErrorRate {
+		return nil, status.Error(codes.Unavailable, "injected error")
+	}
+
+	record, err := s.lookup(req)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]*parker_pb.Value, len(record.Fields)+1)
+	for name, value := range record.Fields {
+		fields[name] = value
+	}
+	if s.padding != nil {
+		fields["padding"] = s.padding
+	}
+	if len(req.Columns) > 0 {
+		projected := make(map[string]*parker_pb.Value, len(req.Columns))
+		for _, column := range req.Columns {
+			if value, ok := fields[column]; ok {
+				projected[column] = value
+			}
+		}
+		fields = projected
+	}
+
+	snapshot := req.Snapshot
+	if snapshot == 0 {
+		snapshot = 1
+	}
+	return &parker_pb.FindResponse{
+		Snapshot: snapshot,
+		Record:   &parker_pb.RecordValue{Fields: fields},
+	}, nil
+}
+
+// lookup returns the record of the key in its key column and partitions.
+// Without a table every key is found, its record holding the key under the
+// key column and the partition values, so what was sent can be validated
+func (s *Server) lookup(req *parker_pb.FindRequest) (*parker_pb.RecordValue, error) {
+	key := keyString(req.Key)
+	if s.options.Table == nil {
+		keyColumn := req.KeyColumn
+		if keyColumn == "" {
+			keyColumn = "key"
+		}
+		record := &parker_pb.RecordValue{Fields: map[string]*parker_pb.Value{
+			keyColumn: {Kind: &parker_pb.Value_StringValue{StringValue: key}},
+		}}
+		for _, partition := range req.Partitions {
+			record.Fields[partition.PartitionKey] = &parker_pb.Value{Kind: &parker_pb.Value_StringValue{StringValue: partition.PartitionValue}}
+		}
+		return record, nil
+	}
+
+	records, err := s.options.Table.Find(req.KeyColumn, key)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	for _, record := range records {
+		if matchesPartitions(record, req.Partitions) {
+			return record, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "key %q not found", key)
+}
+
+// matchesPartitions reports whether a record holds the value of every partition
+func matchesPartitions(record *parker_pb.RecordValue, partitions []*parker_pb.Partition) bool {
+	for _, partition := range partitions {
+		if record.Fields[partition.PartitionKey].GetStringValue() != partition.PartitionValue {
+			return false
+		}
+	}
+	return true
+}
+
+// keyString converts a key into the string form the table is indexed by,
+// matching the HTTP path encoding of the benchmark client
+func keyString(key *parker_pb.Key) string {
+	switch kind := key.GetKind().(type) {
+	case *parker_pb.Key_Int32Value:
+		return strconv.FormatInt(int64(kind.Int32Value), 10)
+	case *parker_pb.Key_Int64Value:
+		return strconv.FormatInt(kind.Int64Value, 10)
+	case *parker_pb.Key_BytesValue:
+		return hex.EncodeToString(kind.BytesValue)
+	default:
+		return key.GetStringValue()
+	}
+}
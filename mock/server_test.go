@@ -0,0 +1,71 @@
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleFind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "table.csv")
+	content := "id,region,name\n1,us:west,alice\n1,eu,bob\n2,eu,carol\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	table, err := LoadTable(path, 0, true)
+	if err != nil {
+		t.Fatalf("LoadTable() error = %v", err)
+	}
+	server := NewServer(Options{Table: table})
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "key", target: "/find/a/t/2", wantStatus: http.StatusOK, wantBody: `"carol"`},
+		{name: "int64 key", target: "/find/a/t/2?key_type=int64", wantStatus: http.StatusOK, wantBody: `"carol"`},
+		{name: "missing key", target: "/find/a/t/3", wantStatus: http.StatusNotFound},
+		{name: "partition", target: "/find/a/t/1?partition.region=eu", wantStatus: http.StatusOK, wantBody: `"bob"`},
+		{name: "partition value with a colon", target: "/find/a/t/1?partition.region=us%3Awest", wantStatus: http.StatusOK, wantBody: `"alice"`},
+		{name: "unmatched partition", target: "/find/a/t/2?partition.region=us", wantStatus: http.StatusNotFound},
+		{name: "key column", target: "/find/a/t/bob?key_column=name", wantStatus: http.StatusOK, wantBody: `"eu"`},
+		{name: "unknown key column", target: "/find/a/t/bob?key_column=nickname", wantStatus: http.StatusBadRequest},
+		{name: "snapshot", target: "/find/a/t/2?snapshot=7", wantStatus: http.StatusOK, wantBody: `"snapshot":"7"`},
+		{name: "columns", target: "/find/a/t/2?columns=name&columns=id", wantStatus: http.StatusOK, wantBody: `"name"`},
+		{name: "invalid int32 key", target: "/find/a/t/x?key_type=int32", wantStatus: http.StatusBadRequest},
+		{name: "invalid bytes key", target: "/find/a/t/zz?key_type=bytes", wantStatus: http.StatusBadRequest},
+		{name: "unknown key type", target: "/find/a/t/2?key_type=float", wantStatus: http.StatusBadRequest},
+		{name: "invalid snapshot", target: "/find/a/t/2?snapshot=latest", wantStatus: http.StatusBadRequest},
+		{name: "repeated parameter", target: "/find/a/t/2?snapshot=1&snapshot=2", wantStatus: http.StatusBadRequest},
+		{name: "unknown parameter", target: "/find/a/t/1?partition=region:eu", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandleFindColumns(t *testing.T) {
+	w := httptest.NewRecorder()
+	server := NewServer(Options{})
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/find/a/t/k?key_column=id&partition.region=eu&columns=id", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if body := w.Body.String(); !strings.Contains(body, `"id"`) || strings.Contains(body, `"region"`) {
+		t.Errorf("body = %s, want only the id column", body)
+	}
+}
@@ -0,0 +1,113 @@
+package mock
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	parker_pb "github.com/ParkerData/parkbench/pb/parker_pb"
+)
+
+// Table is an in-memory table of records, looked up by the key column or, on
+// request, by any other column; several records may share a key when they
+// belong to different partitions
+type Table struct {
+	keyColumn string
+	columns   map[string]bool
+	records   []*parker_pb.RecordValue
+
+	mu      sync.Mutex
+	indexes map[string]map[string][]*parker_pb.RecordValue
+}
+
+// LoadTable reads a CSV file into a table keyed by the keyIndex column; with
+// header the first row names the columns, otherwise they are named c0, c1, ...
+func LoadTable(path string, keyIndex int, header bool) (*Table, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	var names []string
+	if header {
+		names, err = reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read header: %w", err)
+		}
+	}
+	name := func(i int) string {
+		if i < len(names) {
+			return names[i]
+		}
+		return fmt.Sprintf("c%d", i)
+	}
+
+	table := &Table{
+		keyColumn: name(keyIndex),
+		columns:   make(map[string]bool),
+		indexes:   make(map[string]map[string][]*parker_pb.RecordValue),
+	}
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if keyIndex >= len(record) {
+			return nil, fmt.Errorf("row %d has no key column %d", row, keyIndex+1)
+		}
+
+		fields := make(map[string]*parker_pb.Value, len(record))
+		for i, value := range record {
+			fields[name(i)] = &parker_pb.Value{Kind: &parker_pb.Value_StringValue{StringValue: value}}
+			table.columns[name(i)] = true
+		}
+		table.records = append(table.records, &parker_pb.RecordValue{Fields: fields})
+	}
+	table.index(table.keyColumn)
+	return table, nil
+}
+
+// Len returns the number of records
+func (t *Table) Len() int {
+	return len(t.records)
+}
+
+// Find returns the records whose column holds key, column being the key
+// column when empty; it fails when the table has no such column
+func (t *Table) Find(column, key string) ([]*parker_pb.RecordValue, error) {
+	if column == "" {
+		column = t.keyColumn
+	}
+	if !t.columns[column] {
+		return nil, fmt.Errorf("unknown key column %q", column)
+	}
+	return t.index(column)[key], nil
+}
+
+// index returns the records by their value of column, indexing it on first use
+func (t *Table) index(column string) map[string][]*parker_pb.RecordValue {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if index, ok := t.indexes[column]; ok {
+		return index
+	}
+	index := make(map[string][]*parker_pb.RecordValue)
+	for _, record := range t.records {
+		if value, ok := record.Fields[column]; ok {
+			key := value.GetStringValue()
+			index[key] = append(index[key], record)
+		}
+	}
+	t.indexes[column] = index
+	return index
+}
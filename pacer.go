@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/ParkerData/parkbench/config"
+	parker_pb "github.com/ParkerData/parkbench/pb/parker_pb"
+)
+
+// request is a key to look up; intended is the scheduled send time in open-loop mode
+type request struct {
+	key      *parker_pb.Key
+	params   *findParams
+	intended time.Time
+	// expectMiss marks a key expected to be missing from the table
+	expectMiss bool
+	// expected lists the record values to validate the response against
+	expected []expectedValue
+	// unhedged marks a request of the hedging control group
+	unhedged bool
+}
+
+// startTime returns the time latency is measured from, which is the intended
+// send time when paced so queueing delay is not hidden by slow responses
+func (r request) startTime() time.Time {
+	if r.intended.IsZero() {
+		return time.Now()
+	}
+	return r.intended
+}
+
+// paceRequests stamps requests with send times following the arrival
+// distribution at the target rate and releases them no earlier than that
+func paceRequests(ctx context.Context, in <-chan request, out chan<- request, rate float64, arrival string) {
+	defer close(out)
+
+	mean := float64(time.Second) / rate
+	next := time.Now()
+	for req := range in {
+		if wait := time.Until(next); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return
+			}
+		}
+		req.intended = next
+		select {
+		case out <- req:
+		case <-ctx.Done():
+			return
+		}
+
+		switch arrival {
+		case config.ArrivalPoisson:
+			next = next.Add(time.Duration(rand.ExpFloat64() * mean))
+		default:
+			next = next.Add(time.Duration(mean))
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ParkerData/parkbench/config"
+	"github.com/ParkerData/parkbench/stats"
+)
+
+// Result is the machine-readable record of one benchmark run
+type Result struct {
+	Protocol  string        `json:"protocol"`
+	Config    config.Config `json:"config"`
+	Start     time.Time     `json:"start"`
+	End       time.Time     `json:"end"`
+	Intervals []Window      `json:"intervals"`
+	Summary   Window        `json:"summary"`
+	Histogram []Bucket      `json:"histogram"`
+}
+
+// Window holds the statistics of a time window with latencies in milliseconds.
+// Requests and the latencies cover the hits, and only the hedged group when
+// Unhedged holds a control group; Successful and Completed count every request
+type Window struct {
+	Phase         string             `json:"phase"`
+	Start         time.Time          `json:"start"`
+	End           time.Time          `json:"end"`
+	Requests      int64              `json:"requests"`
+	Successful    int64              `json:"successful"`
+	Completed     int64              `json:"completed"`
+	Errors        int64              `json:"errors"`
+	ErrorsByClass map[string]int64   `json:"errorsByClass,omitempty"`
+	AuthFailures  int64              `json:"authFailures"`
+	Timeouts      int64              `json:"timeouts"`
+	Throughput    float64            `json:"throughput"`
+	MeanMs        float64            `json:"meanMs"`
+	MinMs         float64            `json:"minMs"`
+	MaxMs         float64            `json:"maxMs"`
+	StdDevMs      float64            `json:"stdDevMs"`
+	PercentilesMs map[string]float64 `json:"percentilesMs"`
+	// Misses holds the lookups of keys expected to be missing
+	Misses *Window `json:"misses,omitempty"`
+	// HTTP is the stage breakdown of HTTP requests, only in the summary
+	HTTP *HTTPBreakdown `json:"http,omitempty"`
+	// TimeoutLatencies counts the timed out requests included in the latencies
+	TimeoutLatencies int64 `json:"timeoutLatencies,omitempty"`
+	// Attempts holds the latency of every send when requests are retried,
+	// Retries and RetriesByClass count the sends after the first
+	Attempts       *Window          `json:"attempts,omitempty"`
+	Retries        int64            `json:"retries"`
+	RetriesByClass map[string]int64 `json:"retriesByClass,omitempty"`
+	// Hedges counts the hedged sends and Unhedged holds the control group
+	// when requests are hedged
+	Hedges   *Hedges `json:"hedges,omitempty"`
+	Unhedged *Window `json:"unhedged,omitempty"`
+	// Endpoints holds the sends to each endpoint by name, only in the summary
+	Endpoints map[string]Window `json:"endpoints,omitempty"`
+}
+
+// Hedges counts the sends that could be hedged, were hedged and the hedge won
+type Hedges struct {
+	Sends     int64   `json:"sends"`
+	Hedged    int64   `json:"hedged"`
+	Won       int64   `json:"won"`
+	HedgeRate float64 `json:"hedgeRate"`
+	WinRate   float64 `json:"winRate"`
+}
+
+// HTTPBreakdown holds the connection reuse and stage latencies of HTTP requests
+type HTTPBreakdown struct {
+	Requests   int64   `json:"requests"`
+	Reused     int64   `json:"reused"`
+	WasIdle    int64   `json:"wasIdle"`
+	ReuseRatio float64 `json:"reuseRatio"`
+	Stages     []Stage `json:"stages"`
+	// Protocols counts the requests and Connections the new connections by HTTP version
+	Protocols   map[string]int64 `json:"protocols"`
+	Connections map[string]int64 `json:"connections"`
+}
+
+// Stage holds the latency percentiles of one HTTP stage in milliseconds
+type Stage struct {
+	Name          string             `json:"name"`
+	Count         int64              `json:"count"`
+	PercentilesMs map[string]float64 `json:"percentilesMs"`
+}
+
+// Bucket counts the latencies between FromMs and ToMs
+type Bucket struct {
+	FromMs float64 `json:"fromMs"`
+	ToMs   float64 `json:"toMs"`
+	Count  int64   `json:"count"`
+}
+
+// NewResult builds the result document; secrets in cfg are redacted
+func NewResult(protocol string, cfg *config.Config, intervals []stats.Snapshot, summary stats.Snapshot) *Result {
+	result := &Result{
+		Protocol: protocol,
+		Config:   cfg.Redacted(),
+		Summary:  NewWindow(summary),
+	}
+	if len(intervals) > 0 {
+		result.Start = intervals[0].Start
+		result.End = intervals[len(intervals)-1].End
+	}
+	for _, interval := range intervals {
+		result.Intervals = append(result.Intervals, NewWindow(interval))
+	}
+	for _, bucket := range summary.Buckets {
+		result.Histogram = append(result.Histogram, Bucket{
+			FromMs: milliseconds(bucket.From),
+			ToMs:   milliseconds(bucket.To),
+			Count:  bucket.Count,
+		})
+	}
+	return result
+}
+
+// NewWindow converts a snapshot into its exported form
+func NewWindow(s stats.Snapshot) Window {
+	w := Window{
+		Phase:         s.Phase,
+		Start:         s.Start,
+		End:           s.End,
+		Requests:      s.Requests,
+		Successful:    s.Successful(),
+		Completed:     s.Completed(),
+		Errors:        s.ErrorCount(),
+		ErrorsByClass: s.Errors,
+		AuthFailures:  s.AuthFailureCount(),
+		Timeouts:      s.TimeoutCount(),
+		Throughput:    s.Throughput(),
+		MeanMs:        milliseconds(s.Mean),
+		MinMs:         milliseconds(s.Min),
+		MaxMs:         milliseconds(s.Max),
+		StdDevMs:      milliseconds(s.StdDev),
+		PercentilesMs: make(map[string]float64),
+	}
+	w.TimeoutLatencies = s.TimeoutLatencies
+	for _, p := range s.Percentiles {
+		w.PercentilesMs[PercentileName(p.Percentile)] = milliseconds(p.Latency)
+	}
+	if s.Misses != nil {
+		misses := NewWindow(*s.Misses)
+		w.Misses = &misses
+	}
+	if s.HTTP != nil {
+		w.HTTP = newHTTPBreakdown(s.HTTP)
+	}
+	if s.Attempts != nil {
+		attempts := NewWindow(*s.Attempts)
+		w.Attempts = &attempts
+		w.Retries = s.RetryCount()
+		w.RetriesByClass = s.Retries
+	}
+	if s.Hedges != nil {
+		w.Hedges = &Hedges{
+			Sends:     s.Hedges.Sends,
+			Hedged:    s.Hedges.Hedged,
+			Won:       s.Hedges.Won,
+			HedgeRate: s.Hedges.HedgeRate(),
+			WinRate:   s.Hedges.WinRate(),
+		}
+	}
+	if s.Unhedged != nil {
+		unhedged := NewWindow(*s.Unhedged)
+		w.Unhedged = &unhedged
+	}
+	if s.Endpoints != nil {
+		w.Endpoints = make(map[string]Window, len(s.Endpoints))
+		for name, endpoint := range s.Endpoints {
+			w.Endpoints[name] = NewWindow(endpoint)
+		}
+	}
+	return w
+}
+
+func newHTTPBreakdown(b *stats.HTTPBreakdown) *HTTPBreakdown {
+	breakdown := &HTTPBreakdown{
+		Requests:    b.Requests,
+		Reused:      b.Reused,
+		WasIdle:     b.WasIdle,
+		ReuseRatio:  b.ReuseRatio(),
+		Protocols:   b.Protocols,
+		Connections: b.Connections,
+	}
+	for _, s := range b.Stages {
+		stage := Stage{Name: s.Name, Count: s.Count, PercentilesMs: make(map[string]float64)}
+		for _, p := range s.Percentiles {
+			stage.PercentilesMs[PercentileName(p.Percentile)] = milliseconds(p.Latency)
+		}
+		breakdown.Stages = append(breakdown.Stages, stage)
+	}
+	return breakdown
+}
+
+// PercentileName formats a percentile as "p50", "p99.9" and so on
+func PercentileName(p float64) string {
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Comparison is the machine-readable record of a run against several targets
+type Comparison struct {
+	Protocol   string         `json:"protocol"`
+	Concurrent bool           `json:"concurrent"`
+	Targets    []TargetResult `json:"targets"`
+}
+
+// TargetResult is the result of the run against one target of a comparison
+type TargetResult struct {
+	Name string `json:"name"`
+	*Result
+}
+
+// WriteJSON writes a result or comparison document to path
+func WriteJSON(path string, document any) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// WriteIntervalsCSV writes the per-interval time series to path, one row per interval
+func WriteIntervalsCSV(path string, intervals []Window) error {
+	rows := [][]string{intervalHeader()}
+	for _, interval := range intervals {
+		rows = append(rows, intervalRow(interval))
+	}
+	return writeCSV(path, rows)
+}
+
+// WriteComparisonCSV writes the per-interval time series of every target to
+// path, one row per interval led by the name of the target
+func WriteComparisonCSV(path string, comparison *Comparison) error {
+	rows := [][]string{append([]string{"target"}, intervalHeader()...)}
+	for _, target := range comparison.Targets {
+		for _, interval := range target.Intervals {
+			rows = append(rows, append([]string{target.Name}, intervalRow(interval)...))
+		}
+	}
+	return writeCSV(path, rows)
+}
+
+func intervalHeader() []string {
+	header := []string{"start", "end", "phase", "requests", "successful", "completed", "errors", "throughput", "mean_ms", "min_ms", "max_ms", "stddev_ms"}
+	for _, p := range stats.ReportedPercentiles {
+		header = append(header, PercentileName(p)+"_ms")
+	}
+	return append(header, "misses", "miss_mean_ms", "miss_p99_ms", "timeouts", "retries", "hedged", "hedge_wins", "unhedged", "unhedged_mean_ms", "unhedged_p99_ms")
+}
+
+func intervalRow(interval Window) []string {
+	row := []string{
+		interval.Start.Format(time.RFC3339Nano),
+		interval.End.Format(time.RFC3339Nano),
+		interval.Phase,
+		strconv.FormatInt(interval.Requests, 10),
+		strconv.FormatInt(interval.Successful, 10),
+		strconv.FormatInt(interval.Completed, 10),
+		strconv.FormatInt(interval.Errors, 10),
+		formatFloat(interval.Throughput),
+		formatFloat(interval.MeanMs),
+		formatFloat(interval.MinMs),
+		formatFloat(interval.MaxMs),
+		formatFloat(interval.StdDevMs),
+	}
+	for _, p := range stats.ReportedPercentiles {
+		row = append(row, formatFloat(interval.PercentilesMs[PercentileName(p)]))
+	}
+	var misses Window
+	if interval.Misses != nil {
+		misses = *interval.Misses
+	}
+	row = append(row, strconv.FormatInt(misses.Requests, 10), formatFloat(misses.MeanMs), formatFloat(misses.PercentilesMs["p99"]), strconv.FormatInt(interval.Timeouts, 10), strconv.FormatInt(interval.Retries, 10))
+	var hedges Hedges
+	if interval.Hedges != nil {
+		hedges = *interval.Hedges
+	}
+	row = append(row, strconv.FormatInt(hedges.Hedged, 10), strconv.FormatInt(hedges.Won, 10))
+	var unhedged Window
+	if interval.Unhedged != nil {
+		unhedged = *interval.Unhedged
+	}
+	return append(row, strconv.FormatInt(unhedged.Requests, 10), formatFloat(unhedged.MeanMs), formatFloat(unhedged.PercentilesMs["p99"]))
+}
+
+func writeCSV(path string, rows [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.WriteAll(rows)
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 3, 64)
+}
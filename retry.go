@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/ParkerData/parkbench/config"
+	"github.com/ParkerData/parkbench/stats"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// attemptFunc sends a request once and returns its latency since the start of
+// its first attempt, or the error class and error of a failure like httpFind
+// and grpcFind
+type attemptFunc func() (time.Duration, string, error)
+
+// retrier sends a request again while it fails with a retryable error class,
+// the way a production client configured with the same policy would; a nil
+// retrier sends every request once
+type retrier struct {
+	policy  *config.Retry
+	retryOn map[string]bool
+}
+
+func newRetrier(policy *config.Retry) *retrier {
+	if policy == nil {
+		return nil
+	}
+	r := &retrier{policy: policy, retryOn: make(map[string]bool)}
+	for _, class := range policy.RetryOn {
+		r.retryOn[class] = true
+	}
+	return r
+}
+
+// do sends a request until it succeeds, fails with an error not worth
+// retrying or runs out of attempts, and returns the outcome of the last
+// attempt; its latency spans all attempts. The backoff is cut short when ctx
+// ends, ending the request with the outcome it has
+func (r *retrier) do(ctx context.Context, span trace.Span, recorder *stats.Recorder, attempt attemptFunc) (time.Duration, string, error) {
+	if r == nil {
+		return attempt()
+	}
+
+	for n := 1; ; n++ {
+		start := time.Now()
+		latency, class, err := attempt()
+		recorder.RecordAttempt(time.Since(start))
+		if class == "" || !r.retryOn[class] || n >= r.policy.MaxAttempts {
+			return latency, class, err
+		}
+
+		recorder.RecordRetry(class)
+		backoff := r.backoff(n)
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.Int("parkbench.attempt", n),
+			attribute.String("parkbench.result", class),
+			attribute.Int64("parkbench.backoff_us", backoff.Microseconds()),
+		))
+		select {
+		case <-ctx.Done():
+			return latency, class, err
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// backoff returns the wait before the attempt following attempt n
+func (r *retrier) backoff(n int) time.Duration {
+	d := float64(r.policy.InitialBackoff.Duration) * math.Pow(r.policy.Multiplier, float64(n-1))
+	d = math.Min(d, float64(r.policy.MaxBackoff.Duration))
+	d *= 1 + r.policy.Jitter*(2*rand.Float64()-1)
+	return time.Duration(d)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ParkerData/parkbench/config"
+	"github.com/ParkerData/parkbench/stats"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// failing returns an attempt failing with the classes given in turn, then succeeding
+func failing(classes ...string) (attemptFunc, *int) {
+	sent := 0
+	return func() (time.Duration, string, error) {
+		sent++
+		if sent > len(classes) {
+			return time.Millisecond, "", nil
+		}
+		return time.Millisecond, classes[sent-1], errors.New(classes[sent-1])
+	}, &sent
+}
+
+func TestRetrierAttempts(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		classes     []string
+		wantSent    int
+		wantClass   string
+		wantRetries map[string]int64
+	}{
+		{name: "success", maxAttempts: 3, wantSent: 1},
+		{name: "retried to success", maxAttempts: 3, classes: []string{"http_503", stats.ErrorTimeout}, wantSent: 3, wantRetries: map[string]int64{"http_503": 1, stats.ErrorTimeout: 1}},
+		{name: "out of attempts", maxAttempts: 3, classes: []string{"http_503", "http_503", "http_503", "http_503"}, wantSent: 3, wantClass: "http_503", wantRetries: map[string]int64{"http_503": 2}},
+		{name: "single attempt", maxAttempts: 1, classes: []string{"http_503"}, wantSent: 1, wantClass: "http_503"},
+		{name: "not retryable", maxAttempts: 3, classes: []string{"http_400"}, wantSent: 1, wantClass: "http_400"},
+		{name: "miss not retryable", maxAttempts: 3, classes: []string{stats.ErrorMiss}, wantSent: 1, wantClass: stats.ErrorMiss},
+		{name: "retryable then not", maxAttempts: 5, classes: []string{"http_503", "http_400"}, wantSent: 2, wantClass: "http_400", wantRetries: map[string]int64{"http_503": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRetrier(&config.Retry{
+				MaxAttempts:    tt.maxAttempts,
+				RetryOn:        []string{"http_503", stats.ErrorTimeout},
+				InitialBackoff: config.Duration{Duration: time.Microsecond},
+				MaxBackoff:     config.Duration{Duration: time.Microsecond},
+				Multiplier:     2,
+			})
+			recorder := stats.NewRecorder()
+			attempt, sent := failing(tt.classes...)
+			_, class, err := r.do(context.Background(), trace.SpanFromContext(context.Background()), recorder, attempt)
+			if class != tt.wantClass || (err != nil) != (tt.wantClass != "") {
+				t.Errorf("do() = %q, %v, want %q", class, err, tt.wantClass)
+			}
+			if *sent != tt.wantSent {
+				t.Errorf("sent %d times, want %d", *sent, tt.wantSent)
+			}
+			s := recorder.Summary()
+			if s.Attempts == nil || s.Attempts.Requests != int64(tt.wantSent) {
+				t.Errorf("recorded attempts = %+v, want %d", s.Attempts, tt.wantSent)
+			}
+			if s.RetryCount() != int64(tt.wantSent-1) {
+				t.Errorf("%d retries recorded, want %d", s.RetryCount(), tt.wantSent-1)
+			}
+			for class, want := range tt.wantRetries {
+				if s.Retries[class] != want {
+					t.Errorf("retries of %s = %d, want %d", class, s.Retries[class], want)
+				}
+			}
+		})
+	}
+}
+
+func TestRetrierNil(t *testing.T) {
+	attempt, sent := failing("http_503")
+	var r *retrier
+	if _, class, _ := r.do(context.Background(), trace.SpanFromContext(context.Background()), stats.NewRecorder(), attempt); class != "http_503" || *sent != 1 {
+		t.Errorf("nil retrier: %q after %d sends, want http_503 after 1", class, *sent)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := &config.Retry{
+		InitialBackoff: config.Duration{Duration: 10 * time.Millisecond},
+		MaxBackoff:     config.Duration{Duration: 100 * time.Millisecond},
+		Multiplier:     3,
+	}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: 10 * time.Millisecond},
+		{attempt: 2, want: 30 * time.Millisecond},
+		{attempt: 3, want: 90 * time.Millisecond},
+		{attempt: 4, want: 100 * time.Millisecond},
+		{attempt: 50, want: 100 * time.Millisecond},
+	}
+	for _, jitter := range []float64{0, 0.2} {
+		policy.Jitter = jitter
+		r := newRetrier(policy)
+		for _, tt := range tests {
+			low := time.Duration(float64(tt.want) * (1 - jitter))
+			high := time.Duration(float64(tt.want) * (1 + jitter))
+			var seenLow, seenHigh bool
+			for range 1000 {
+				got := r.backoff(tt.attempt)
+				if got < low || got > high {
+					t.Fatalf("jitter %v: backoff(%d) = %v, want within [%v, %v]", jitter, tt.attempt, got, low, high)
+				}
+				seenLow = seenLow || got < tt.want-(high-low)/4
+				seenHigh = seenHigh || got > tt.want+(high-low)/4
+			}
+			if jitter > 0 && !(seenLow && seenHigh) {
+				t.Errorf("jitter %v: backoff(%d) not spread over [%v, %v]", jitter, tt.attempt, low, high)
+			}
+		}
+	}
+}
+
+func TestRetrierCancelledDuringBackoff(t *testing.T) {
+	r := newRetrier(&config.Retry{
+		MaxAttempts:    3,
+		RetryOn:        []string{"http_503"},
+		InitialBackoff: config.Duration{Duration: time.Hour},
+		MaxBackoff:     config.Duration{Duration: time.Hour},
+		Multiplier:     2,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	attempt, sent := failing("http_503", "http_503")
+	start := time.Now()
+	_, class, err := r.do(ctx, trace.SpanFromContext(ctx), stats.NewRecorder(), attempt)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("do() returned after %v, want soon after the cancel", elapsed)
+	}
+	if class != "http_503" || err == nil || *sent != 1 {
+		t.Errorf("do() = %q, %v after %d sends, want the http_503 of the first send", class, err, *sent)
+	}
+}
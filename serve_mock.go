@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/ParkerData/parkbench/mock"
+	parker_pb "github.com/ParkerData/parkbench/pb/parker_pb"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+)
+
+// serveMock runs the mock Parker gateway until the process is stopped
+func serveMock(args []string) {
+	flags := flag.NewFlagSet("serve-mock", flag.ExitOnError)
+	grpcAddress := flags.String("grpc-addr", ":50051", "gRPC listen address, empty to disable")
+	httpAddress := flags.String("http-addr", ":8080", "HTTP listen address, empty to disable")
+	csvPath := flags.String("csv", "", "CSV file with the table records (default: every key is found)")
+	keyIndex := flags.Int("key-index", 0, "CSV column holding the key")
+	header := flags.Bool("header", false, "The first CSV row names the columns")
+	latency := flags.String("latency", "", `Injected latency: "fixed:2ms", "uniform:1ms-5ms", "normal:5ms,1ms" or "exponential:2ms"`)
+	errorRate := flags.Float64("error-rate", 0, "Fraction of requests that fail with UNAVAILABLE / -error-status")
+	errorStatus := flags.Int("error-status", http.StatusServiceUnavailable, "HTTP status of injected errors")
+	responseSize := flags.Int("response-size", 0, "Bytes of padding added to every record")
+	flags.Parse(args)
+
+	options := mock.Options{
+		ErrorRate:    *errorRate,
+		ErrorStatus:  *errorStatus,
+		ResponseSize: *responseSize,
+	}
+
+	var err error
+	options.Latency, err = mock.ParseLatency(*latency)
+	if err != nil {
+		log.Fatalf("Invalid latency: %v", err)
+	}
+
+	if *csvPath != "" {
+		options.Table, err = mock.LoadTable(*csvPath, *keyIndex, *header)
+		if err != nil {
+			log.Fatalf("Failed to load table from %s: %v", *csvPath, err)
+		}
+		log.Printf("Loaded %d records from %s", options.Table.Len(), *csvPath)
+	}
+
+	server := mock.NewServer(options)
+	errChan := make(chan error, 2)
+
+	if *grpcAddress != "" {
+		listener, err := net.Listen("tcp", *grpcAddress)
+		if err != nil {
+			log.Fatalf("Failed to listen on %s: %v", *grpcAddress, err)
+		}
+		grpcServer := grpc.NewServer()
+		parker_pb.RegisterGatewayServer(grpcServer, server)
+		log.Printf("Mock gateway serving gRPC on %s", listener.Addr())
+		go func() { errChan <- grpcServer.Serve(listener) }()
+	}
+
+	if *httpAddress != "" {
+		listener, err := net.Listen("tcp", *httpAddress)
+		if err != nil {
+			log.Fatalf("Failed to listen on %s: %v", *httpAddress, err)
+		}
+		log.Printf("Mock gateway serving HTTP on %s", listener.Addr())
+		// Accept h2c with prior knowledge next to HTTP/1.1
+		go func() { errChan <- http.Serve(listener, h2c.NewHandler(server, &http2.Server{})) }()
+	}
+
+	if *grpcAddress == "" && *httpAddress == "" {
+		log.Fatalf("Both -grpc-addr and -http-addr are empty")
+	}
+	log.Fatalf("Mock gateway stopped: %v", <-errChan)
+}
@@ -0,0 +1,49 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// comparedPercentiles are the percentiles of the comparison table
+var comparedPercentiles = []float64{50, 90, 99, 99.9}
+
+// WriteComparison prints the summaries of the runs against several targets
+// side by side, one row per target in the order given. A hedging control
+// group gets a row of its own, the row of the target holding the latencies
+// of the hedged requests and the counts of both groups
+func WriteComparison(w io.Writer, names []string, summaries []Snapshot) {
+	fmt.Fprintf(w, "\nComparison:\n")
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "  Target\tRequests\tErrors\tRequests/s\tMean")
+	for _, p := range comparedPercentiles {
+		fmt.Fprintf(table, "\tP%v", p)
+	}
+	fmt.Fprintf(table, "\tMax\n")
+	controlled := false
+	for i, s := range summaries {
+		name := names[i]
+		if s.Unhedged != nil {
+			name += " (hedged)"
+			controlled = true
+		}
+		writeComparisonRow(table, name, s.Completed(), fmt.Sprint(s.ErrorCount()), s.Throughput(), s)
+		if s.Unhedged != nil {
+			writeComparisonRow(table, names[i]+" (unhedged)", s.Unhedged.Requests, "-", s.Unhedged.Throughput(), *s.Unhedged)
+		}
+	}
+	table.Flush()
+	if controlled {
+		fmt.Fprintf(w, "  The requests, errors and requests/s of hedged rows count both groups, their latencies the hedged requests only\n")
+	}
+}
+
+// writeComparisonRow prints the counts given and the latencies of s
+func writeComparisonRow(table io.Writer, name string, requests int64, errors string, throughput float64, s Snapshot) {
+	fmt.Fprintf(table, "  %s\t%d\t%s\t%.2f\t%v", name, requests, errors, throughput, s.Mean)
+	for _, p := range comparedPercentiles {
+		fmt.Fprintf(table, "\t%v", s.percentile(p))
+	}
+	fmt.Fprintf(table, "\t%v\n", s.Max)
+}
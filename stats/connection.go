@@ -0,0 +1,143 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Stages of an HTTP request timed with httptrace. Connection setup stages
+// only happen on new connections; ConnWait covers waiting for an idle pooled
+// connection or setting up a new one
+const (
+	StageConnWait  = "conn_wait"
+	StageDNS       = "dns"
+	StageConnect   = "connect"
+	StageTLS       = "tls"
+	StageFirstByte = "first_byte"
+	StageTransfer  = "transfer"
+)
+
+// HTTPStages lists the stages in the order they happen
+var HTTPStages = []string{StageConnWait, StageDNS, StageConnect, StageTLS, StageFirstByte, StageTransfer}
+
+// HTTPTiming holds the stage durations of one HTTP request; stages that did
+// not happen, such as DNS on a reused connection, are absent. Protocol is the
+// HTTP version of the response, e.g. "HTTP/2.0"
+type HTTPTiming struct {
+	Stages   map[string]time.Duration
+	Reused   bool
+	WasIdle  bool
+	Protocol string
+}
+
+// httpWindow accumulates the HTTP timings of a window
+type httpWindow struct {
+	requests    int64
+	reused      int64
+	wasIdle     int64
+	stages      map[string]*hdrhistogram.Histogram
+	protocols   map[string]int64
+	connections map[string]int64
+}
+
+func (w *window) recordHTTP(t HTTPTiming) {
+	if w.http == nil {
+		w.http = &httpWindow{
+			stages:      make(map[string]*hdrhistogram.Histogram),
+			protocols:   make(map[string]int64),
+			connections: make(map[string]int64),
+		}
+	}
+	h := w.http
+	h.requests++
+	h.protocols[t.Protocol]++
+	if t.Reused {
+		h.reused++
+	} else {
+		h.connections[t.Protocol]++
+	}
+	if t.WasIdle {
+		h.wasIdle++
+	}
+	for stage, d := range t.Stages {
+		if h.stages[stage] == nil {
+			h.stages[stage] = newHistogram()
+		}
+		h.stages[stage].RecordValue(toMicros(d))
+	}
+}
+
+// HTTPBreakdown summarizes where the time of HTTP requests went, how often
+// pooled connections were reused and which HTTP versions were spoken
+type HTTPBreakdown struct {
+	Requests int64
+	Reused   int64
+	WasIdle  int64
+	Stages   []Stage
+	// Protocols counts the requests and Connections the new connections by HTTP version
+	Protocols   map[string]int64
+	Connections map[string]int64
+}
+
+// Stage holds the latency percentiles of one HTTP stage over the Count
+// requests it happened in
+type Stage struct {
+	Name        string
+	Count       int64
+	Percentiles []Percentile
+}
+
+func newHTTPBreakdown(h *httpWindow) *HTTPBreakdown {
+	if h == nil {
+		return nil
+	}
+	b := &HTTPBreakdown{
+		Requests:    h.requests,
+		Reused:      h.reused,
+		WasIdle:     h.wasIdle,
+		Protocols:   maps.Clone(h.protocols),
+		Connections: maps.Clone(h.connections),
+	}
+	for _, name := range HTTPStages {
+		histogram := h.stages[name]
+		if histogram == nil {
+			continue
+		}
+		stage := Stage{Name: name, Count: histogram.TotalCount()}
+		for _, p := range ReportedPercentiles {
+			stage.Percentiles = append(stage.Percentiles, Percentile{
+				Percentile: p,
+				Latency:    fromMicros(histogram.ValueAtPercentile(p)),
+			})
+		}
+		b.Stages = append(b.Stages, stage)
+	}
+	return b
+}
+
+// ReuseRatio returns the fraction of requests sent on a reused connection
+func (b *HTTPBreakdown) ReuseRatio() float64 {
+	if b.Requests == 0 {
+		return 0
+	}
+	return float64(b.Reused) / float64(b.Requests)
+}
+
+func (b *HTTPBreakdown) write(w io.Writer) {
+	for _, protocol := range slices.Sorted(maps.Keys(b.Protocols)) {
+		fmt.Fprintf(w, "HTTP Protocol %s: %d requests on %d new connections\n", protocol, b.Protocols[protocol], b.Connections[protocol])
+	}
+	fmt.Fprintf(w, "HTTP Connection Reuse: %.2f%% (%d of %d requests, %d on idle connections)\n", b.ReuseRatio()*100, b.Reused, b.Requests, b.WasIdle)
+	for _, stage := range b.Stages {
+		fmt.Fprintf(w, "HTTP %s (%d requests):", stage.Name, stage.Count)
+		for _, p := range stage.Percentiles {
+			fmt.Fprintf(w, " P%v %v", p.Percentile, p.Latency)
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,60 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"text/tabwriter"
+	"time"
+)
+
+// endpointPercentiles are the percentiles of the per-endpoint table
+var endpointPercentiles = []float64{50, 99, 99.9}
+
+func (w *window) recordEndpoint(name string, v int64, class string) {
+	if w.endpoints == nil {
+		w.endpoints = make(map[string]*window)
+	}
+	endpoint, ok := w.endpoints[name]
+	if !ok {
+		endpoint = newWindow()
+		w.endpoints[name] = endpoint
+	}
+	if class == "" || class == ErrorMiss {
+		endpoint.latencies.RecordValue(v)
+	} else {
+		endpoint.errors[class]++
+	}
+}
+
+func newEndpoints(endpoints map[string]*window, start, end time.Time) map[string]Snapshot {
+	if endpoints == nil {
+		return nil
+	}
+	snapshots := make(map[string]Snapshot, len(endpoints))
+	for name, w := range endpoints {
+		snapshots[name] = newSnapshot(w, start, end)
+	}
+	return snapshots
+}
+
+// writeEndpoints prints one row per endpoint so a slow or failing one stands out
+func writeEndpoints(w io.Writer, endpoints map[string]Snapshot) {
+	fmt.Fprintf(w, "Endpoints:\n")
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "  Endpoint\tSends\tErrors\tMean")
+	for _, p := range endpointPercentiles {
+		fmt.Fprintf(table, "\tP%v", p)
+	}
+	fmt.Fprintf(table, "\tMax\n")
+	for _, name := range slices.Sorted(maps.Keys(endpoints)) {
+		s := endpoints[name]
+		fmt.Fprintf(table, "  %s\t%d\t%d\t%v", name, s.Completed(), s.ErrorCount(), s.Mean)
+		for _, p := range endpointPercentiles {
+			fmt.Fprintf(table, "\t%v", s.percentile(p))
+		}
+		fmt.Fprintf(table, "\t%v\n", s.Max)
+	}
+	table.Flush()
+}
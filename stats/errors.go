@@ -0,0 +1,29 @@
+package stats
+
+// Error classes shared by both protocols; protocol specific status codes are
+// reported as "http_<code>" and "grpc_<code>"
+const (
+	ErrorMiss      = "miss"
+	ErrorTimeout   = "timeout"
+	ErrorTransport = "transport"
+	// ErrorUnexpectedHit is a key expected to be missing that was found
+	ErrorUnexpectedHit = "unexpected_hit"
+	// Validation failures of a found record against the expected values
+	ErrorMissingColumn = "missing_column"
+	ErrorTypeMismatch  = "type_mismatch"
+	ErrorValueMismatch = "value_mismatch"
+	// ErrorInvalidResponse is a response body that could not be decoded
+	ErrorInvalidResponse = "invalid_response"
+	// ErrorAuthToken is a request not sent because no token could be obtained
+	ErrorAuthToken = "auth_token"
+)
+
+// IsAuthFailure reports whether an error class means the request was not
+// authorized: no token, or a token the server rejected
+func IsAuthFailure(class string) bool {
+	switch class {
+	case ErrorAuthToken, "http_401", "http_403", "grpc_Unauthenticated", "grpc_PermissionDenied":
+		return true
+	}
+	return false
+}
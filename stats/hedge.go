@@ -0,0 +1,48 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+)
+
+// HedgeCounts counts the sends that could be hedged, the ones a copy was
+// sent for because the reply was late and the ones the copy won
+type HedgeCounts struct {
+	Sends  int64
+	Hedged int64
+	Won    int64
+}
+
+func (w *window) recordHedge(hedged, won bool) {
+	if w.hedges == nil {
+		w.hedges = &HedgeCounts{}
+	}
+	w.hedges.Sends++
+	if hedged {
+		w.hedges.Hedged++
+	}
+	if won {
+		w.hedges.Won++
+	}
+}
+
+// HedgeRate returns the fraction of sends that were hedged
+func (h *HedgeCounts) HedgeRate() float64 {
+	if h.Sends == 0 {
+		return 0
+	}
+	return float64(h.Hedged) / float64(h.Sends)
+}
+
+// WinRate returns the fraction of hedged sends the copy replied to first
+func (h *HedgeCounts) WinRate() float64 {
+	if h.Hedged == 0 {
+		return 0
+	}
+	return float64(h.Won) / float64(h.Hedged)
+}
+
+func (h *HedgeCounts) write(w io.Writer) {
+	fmt.Fprintf(w, "Hedged: %.2f%% (%d of %d sends)\n", h.HedgeRate()*100, h.Hedged, h.Sends)
+	fmt.Fprintf(w, "Hedge Wins: %.2f%% (%d of %d hedged)\n", h.WinRate()*100, h.Won, h.Hedged)
+}
@@ -0,0 +1,385 @@
+package stats
+
+import (
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+const (
+	// lowestLatency and highestLatency bound the histograms in microseconds
+	lowestLatency  = 1
+	highestLatency = int64(time.Hour / time.Microsecond)
+	// significantDigits keeps value precision at 0.1% across the whole range
+	significantDigits = 3
+)
+
+// Phases of a run; only samples recorded during PhaseMeasure count toward the summary
+const (
+	PhaseWarmup   = "warmup"
+	PhaseMeasure  = "measure"
+	PhaseCooldown = "cooldown"
+)
+
+// Observer receives every recorded request as it completes, e.g. to export
+// live metrics; it is called concurrently from the workers
+type Observer interface {
+	ObserveSuccess(latency time.Duration)
+	ObserveMiss(latency time.Duration)
+	ObserveError(class string)
+}
+
+// Recorder collects request latencies into HDR histograms with bounded memory
+// and counts failed requests by error class
+type Recorder struct {
+	mu       sync.Mutex
+	phase    string
+	start    time.Time
+	end      time.Time
+	total    *window
+	interval *window
+	last     time.Time
+	observer Observer
+	inFlight atomic.Int64
+	// recordTimeouts adds the latency of timed out requests to the histograms
+	recordTimeouts bool
+}
+
+// window accumulates the samples of one reporting period; misses is only
+// allocated once a request for a missing key was expected and answered so,
+// and http once an HTTP request was timed, which only happens in the total.
+// timeouts counts the latencies that belong to timed out requests; attempts
+// holds the latency of every send once requests are retried, and hedges and
+// unhedged the hedging counts and control group once requests are hedged
+type window struct {
+	latencies *hdrhistogram.Histogram
+	misses    *hdrhistogram.Histogram
+	errors    map[string]int64
+	http      *httpWindow
+	timeouts  int64
+	attempts  *hdrhistogram.Histogram
+	retries   map[string]int64
+	hedges    *HedgeCounts
+	unhedged  *hdrhistogram.Histogram
+	// endpoints holds a window per endpoint, only in the total
+	endpoints map[string]*window
+}
+
+func newWindow() *window {
+	return &window{
+		latencies: newHistogram(),
+		errors:    make(map[string]int64),
+		retries:   make(map[string]int64),
+	}
+}
+
+func newHistogram() *hdrhistogram.Histogram {
+	return hdrhistogram.New(lowestLatency, highestLatency, significantDigits)
+}
+
+func (w *window) recordTimeout(v int64, record bool) {
+	w.errors[ErrorTimeout]++
+	if record {
+		w.latencies.RecordValue(v)
+		w.timeouts++
+	}
+}
+
+func (w *window) recordAttempt(v int64) {
+	if w.attempts == nil {
+		w.attempts = newHistogram()
+	}
+	w.attempts.RecordValue(v)
+}
+
+func (w *window) recordUnhedged(v int64) {
+	if w.unhedged == nil {
+		w.unhedged = newHistogram()
+	}
+	w.unhedged.RecordValue(v)
+}
+
+func (w *window) recordMiss(v int64) {
+	if w.misses == nil {
+		w.misses = newHistogram()
+	}
+	w.misses.RecordValue(v)
+}
+
+// NewRecorder creates a recorder that starts measuring now
+func NewRecorder() *Recorder {
+	now := time.Now()
+	return &Recorder{
+		phase:    PhaseMeasure,
+		start:    now,
+		last:     now,
+		total:    newWindow(),
+		interval: newWindow(),
+	}
+}
+
+// SetObserver passes every request recorded from now on to observer
+func (r *Recorder) SetObserver(observer Observer) {
+	r.mu.Lock()
+	r.observer = observer
+	r.mu.Unlock()
+}
+
+// SetRecordTimeouts makes RecordTimeout add the latency of timed out requests
+// to the latency histograms as well as counting them
+func (r *Recorder) SetRecordTimeouts(record bool) {
+	r.mu.Lock()
+	r.recordTimeouts = record
+	r.mu.Unlock()
+}
+
+// Begin marks a request as sent; End marks its response as received
+func (r *Recorder) Begin() {
+	r.inFlight.Add(1)
+}
+
+// End balances a call to Begin
+func (r *Recorder) End() {
+	r.inFlight.Add(-1)
+}
+
+// InFlight returns the number of requests waiting for a response
+func (r *Recorder) InFlight() int64 {
+	return r.inFlight.Load()
+}
+
+// Record adds the latency of one successful request
+func (r *Recorder) Record(latency time.Duration) {
+	v := toMicros(latency)
+
+	r.mu.Lock()
+	if r.phase == PhaseMeasure {
+		r.total.latencies.RecordValue(v)
+	}
+	r.interval.latencies.RecordValue(v)
+	observer := r.observer
+	r.mu.Unlock()
+
+	if observer != nil {
+		observer.ObserveSuccess(latency)
+	}
+}
+
+// RecordMiss adds the latency of one request for a missing key that was
+// expected not to be found
+func (r *Recorder) RecordMiss(latency time.Duration) {
+	v := toMicros(latency)
+
+	r.mu.Lock()
+	if r.phase == PhaseMeasure {
+		r.total.recordMiss(v)
+	}
+	r.interval.recordMiss(v)
+	observer := r.observer
+	r.mu.Unlock()
+
+	if observer != nil {
+		observer.ObserveMiss(latency)
+	}
+}
+
+// RecordError counts one failed request under the given error class
+func (r *Recorder) RecordError(class string) {
+	r.mu.Lock()
+	if r.phase == PhaseMeasure {
+		r.total.errors[class]++
+	}
+	r.interval.errors[class]++
+	observer := r.observer
+	r.mu.Unlock()
+
+	if observer != nil {
+		observer.ObserveError(class)
+	}
+}
+
+// RecordAttempt adds the latency of one send of a request that may be
+// retried, from sending it to its outcome whether it failed or not
+func (r *Recorder) RecordAttempt(latency time.Duration) {
+	v := toMicros(latency)
+
+	r.mu.Lock()
+	if r.phase == PhaseMeasure {
+		r.total.recordAttempt(v)
+	}
+	r.interval.recordAttempt(v)
+	r.mu.Unlock()
+}
+
+// RecordRetry counts one request sent again after failing with the given
+// error class; the failure itself is not an error unless the last attempt fails
+func (r *Recorder) RecordRetry(class string) {
+	r.mu.Lock()
+	if r.phase == PhaseMeasure {
+		r.total.retries[class]++
+	}
+	r.interval.retries[class]++
+	r.mu.Unlock()
+}
+
+// RecordHedge counts one send that could be hedged, whether a copy was sent
+// because the reply was late and whether the copy replied first
+func (r *Recorder) RecordHedge(hedged, won bool) {
+	r.mu.Lock()
+	if r.phase == PhaseMeasure {
+		r.total.recordHedge(hedged, won)
+	}
+	r.interval.recordHedge(hedged, won)
+	r.mu.Unlock()
+}
+
+// RecordUnhedged adds the latency of one successful request of the control
+// group sent without hedging; it is kept apart from the hedged latencies
+func (r *Recorder) RecordUnhedged(latency time.Duration) {
+	v := toMicros(latency)
+
+	r.mu.Lock()
+	if r.phase == PhaseMeasure {
+		r.total.recordUnhedged(v)
+	}
+	r.interval.recordUnhedged(v)
+	observer := r.observer
+	r.mu.Unlock()
+
+	if observer != nil {
+		observer.ObserveSuccess(latency)
+	}
+}
+
+// RecordTimeout counts one request that timed out after latency as an
+// ErrorTimeout error
+func (r *Recorder) RecordTimeout(latency time.Duration) {
+	v := toMicros(latency)
+
+	r.mu.Lock()
+	if r.phase == PhaseMeasure {
+		r.total.recordTimeout(v, r.recordTimeouts)
+	}
+	r.interval.recordTimeout(v, r.recordTimeouts)
+	observer := r.observer
+	r.mu.Unlock()
+
+	if observer != nil {
+		observer.ObserveError(ErrorTimeout)
+	}
+}
+
+// RecordEndpoint adds the latency of one send to an endpoint, answered or
+// failed with the error class; like RecordHTTP it is only kept for the summary
+func (r *Recorder) RecordEndpoint(name string, latency time.Duration, class string) {
+	v := toMicros(latency)
+
+	r.mu.Lock()
+	if r.phase == PhaseMeasure {
+		r.total.recordEndpoint(name, v, class)
+	}
+	r.mu.Unlock()
+}
+
+// RecordHTTP adds the stage timings of one HTTP request that got a response;
+// they are only kept for the summary
+func (r *Recorder) RecordHTTP(timing HTTPTiming) {
+	r.mu.Lock()
+	if r.phase == PhaseMeasure {
+		r.total.recordHTTP(timing)
+	}
+	r.mu.Unlock()
+}
+
+// SetPhase switches the run phase; entering PhaseMeasure discards earlier
+// samples from the summary and leaving it stops the summary clock
+func (r *Recorder) SetPhase(phase string) {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if phase == PhaseMeasure && r.phase != PhaseMeasure {
+		r.total = newWindow()
+		r.start = now
+	}
+	if phase != PhaseMeasure && r.phase == PhaseMeasure {
+		r.end = now
+	}
+	r.phase = phase
+}
+
+// Interval returns the statistics gathered since the previous call and starts a new interval
+func (r *Recorder) Interval() Snapshot {
+	now := time.Now()
+
+	r.mu.Lock()
+	w := r.interval
+	r.interval = newWindow()
+	start := r.last
+	r.last = now
+	phase := r.phase
+	r.mu.Unlock()
+
+	s := newSnapshot(w, start, now)
+	s.Phase = phase
+	return s
+}
+
+// Summary returns the statistics gathered during the measurement phase
+func (r *Recorder) Summary() Snapshot {
+	end := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.phase != PhaseMeasure && !r.end.IsZero() {
+		end = r.end
+	}
+	s := newSnapshot(r.total, r.start, end)
+	s.Phase = PhaseMeasure
+	s.Buckets = newBuckets(r.total.latencies)
+	s.HTTP = newHTTPBreakdown(r.total.http)
+	s.Endpoints = newEndpoints(r.total.endpoints, r.start, end)
+	return s
+}
+
+// Counts returns the request and error counts of the measurement phase
+// without the latency statistics of Summary, cheap enough to check every second
+func (r *Recorder) Counts() Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w := r.total
+	s := Snapshot{
+		Phase:    PhaseMeasure,
+		Requests: w.latencies.TotalCount() - w.timeouts,
+		Errors:   maps.Clone(w.errors),
+	}
+	if w.misses != nil {
+		s.Misses = &Snapshot{Requests: w.misses.TotalCount()}
+	}
+	if w.unhedged != nil {
+		s.Unhedged = &Snapshot{Requests: w.unhedged.TotalCount()}
+	}
+	return s
+}
+
+// toMicros clamps a latency into the trackable histogram range
+func toMicros(d time.Duration) int64 {
+	v := int64(d / time.Microsecond)
+	if v < lowestLatency {
+		return lowestLatency
+	}
+	if v > highestLatency {
+		return highestLatency
+	}
+	return v
+}
+
+func fromMicros(v int64) time.Duration {
+	return time.Duration(v) * time.Microsecond
+}
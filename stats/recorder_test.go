@@ -0,0 +1,119 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+// within reports whether got is within the 0.1% precision of the histograms of want
+func within(got, want time.Duration) bool {
+	diff := got - want
+	return diff.Abs() <= want/1000+time.Microsecond
+}
+
+func TestSummaryPercentiles(t *testing.T) {
+	r := NewRecorder()
+	for i := 1; i <= 1000; i++ {
+		r.Record(time.Duration(i) * time.Millisecond)
+	}
+	s := r.Summary()
+
+	if s.Requests != 1000 {
+		t.Fatalf("Requests = %d, want 1000", s.Requests)
+	}
+	for _, tt := range []struct {
+		name      string
+		got, want time.Duration
+	}{
+		{"min", s.Min, time.Millisecond},
+		{"max", s.Max, time.Second},
+		{"mean", s.Mean, 500500 * time.Microsecond},
+		{"P50", s.percentile(50), 500 * time.Millisecond},
+		{"P90", s.percentile(90), 900 * time.Millisecond},
+		{"P99", s.percentile(99), 990 * time.Millisecond},
+		{"P99.9", s.percentile(99.9), 999 * time.Millisecond},
+		{"P99.99", s.percentile(99.99), time.Second},
+	} {
+		if !within(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if len(s.Percentiles) != len(ReportedPercentiles) {
+		t.Errorf("%d percentiles, want %d", len(s.Percentiles), len(ReportedPercentiles))
+	}
+
+	var bucketed int64
+	for _, b := range s.Buckets {
+		bucketed += b.Count
+	}
+	if bucketed != 1000 {
+		t.Errorf("buckets hold %d latencies, want 1000", bucketed)
+	}
+}
+
+func TestLatencyBounds(t *testing.T) {
+	tests := []struct {
+		latency time.Duration
+		want    time.Duration
+	}{
+		{latency: 0, want: time.Microsecond},
+		{latency: 500 * time.Nanosecond, want: time.Microsecond},
+		{latency: 1500 * time.Microsecond, want: 1500 * time.Microsecond},
+		{latency: 2 * time.Hour, want: time.Hour},
+	}
+	for _, tt := range tests {
+		r := NewRecorder()
+		r.Record(tt.latency)
+		if got := r.Summary().Max; !within(got, tt.want) {
+			t.Errorf("Record(%v): max = %v, want %v", tt.latency, got, tt.want)
+		}
+	}
+}
+
+func TestIntervalAndPhases(t *testing.T) {
+	r := NewRecorder()
+	r.SetPhase(PhaseWarmup)
+	r.Record(time.Second)
+	if s := r.Interval(); s.Requests != 1 || s.Phase != PhaseWarmup {
+		t.Errorf("warmup interval: %d requests in phase %q", s.Requests, s.Phase)
+	}
+
+	r.SetPhase(PhaseMeasure)
+	r.Record(time.Millisecond)
+	r.Record(time.Millisecond)
+	if s := r.Interval(); s.Requests != 2 || s.Phase != PhaseMeasure {
+		t.Errorf("measure interval: %d requests in phase %q", s.Requests, s.Phase)
+	}
+	if s := r.Interval(); s.Requests != 0 {
+		t.Errorf("empty interval: %d requests", s.Requests)
+	}
+
+	r.SetPhase(PhaseCooldown)
+	r.Record(time.Second)
+	s := r.Summary()
+	if s.Requests != 2 || !within(s.Max, time.Millisecond) {
+		t.Errorf("summary: %d requests, max %v; want the 2 measured requests", s.Requests, s.Max)
+	}
+}
+
+func TestCounts(t *testing.T) {
+	r := NewRecorder()
+	r.SetRecordTimeouts(true)
+	for range 5 {
+		r.Record(time.Millisecond)
+	}
+	r.RecordMiss(time.Millisecond)
+	r.RecordUnhedged(time.Millisecond)
+	r.RecordUnhedged(time.Millisecond)
+	r.RecordError("http_503")
+	r.RecordTimeout(time.Second)
+
+	counts, summary := r.Counts(), r.Summary()
+	if counts.Requests != 5 || counts.Successful() != summary.Successful() || counts.Completed() != summary.Completed() {
+		t.Errorf("counts: %d requests, %d successful, %d completed; summary: %d requests, %d successful, %d completed",
+			counts.Requests, counts.Successful(), counts.Completed(), summary.Requests, summary.Successful(), summary.Completed())
+	}
+	if counts.BudgetErrorCount() != 2 || counts.BudgetErrorCount() != summary.BudgetErrorCount() {
+		t.Errorf("counts: %d budget errors, summary: %d, want 2", counts.BudgetErrorCount(), summary.BudgetErrorCount())
+	}
+}
@@ -0,0 +1,305 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// ReportedPercentiles are the percentiles included in every snapshot
+var ReportedPercentiles = []float64{50, 90, 95, 99, 99.9, 99.99}
+
+// Percentile is the latency at a given percentile
+type Percentile struct {
+	Percentile float64
+	Latency    time.Duration
+}
+
+// Bucket counts the latencies that fall into [From, To]
+type Bucket struct {
+	From  time.Duration
+	To    time.Duration
+	Count int64
+}
+
+// Snapshot holds the latency statistics of a time window; latencies only
+// cover successful requests, and timed out ones when they are recorded, while
+// failed ones are counted in Errors by class and lookups of keys expected to
+// be missing are kept apart in Misses
+type Snapshot struct {
+	Phase       string
+	Start       time.Time
+	End         time.Time
+	Requests    int64
+	Errors      map[string]int64
+	Mean        time.Duration
+	Min         time.Duration
+	Max         time.Duration
+	StdDev      time.Duration
+	Percentiles []Percentile
+	// Buckets is the non-empty part of the histogram, only filled in by Summary
+	Buckets []Bucket
+	Misses  *Snapshot
+	// HTTP is the stage breakdown of HTTP requests, only filled in by Summary
+	HTTP *HTTPBreakdown
+	// TimeoutLatencies counts the timed out requests included in the latencies
+	TimeoutLatencies int64
+	// Attempts holds the latency of every send when requests are retried,
+	// while the latencies above span all attempts of a request; Retries
+	// counts the retries by the error class that caused them
+	Attempts *Snapshot
+	Retries  map[string]int64
+	// Hedges counts the hedged sends when requests are hedged; Unhedged holds
+	// the successful requests of the control group sent without hedging,
+	// which are not part of the latencies above
+	Hedges   *HedgeCounts
+	Unhedged *Snapshot
+	// Endpoints holds the sends to each endpoint by name, only filled in by
+	// Summary; their latencies cover the sends that got an answer
+	Endpoints map[string]Snapshot
+}
+
+func newSnapshot(w *window, start, end time.Time) Snapshot {
+	s := newLatencySnapshot(w.latencies, start, end)
+	s.Requests -= w.timeouts
+	s.TimeoutLatencies = w.timeouts
+	s.Errors = maps.Clone(w.errors)
+	if w.misses != nil {
+		misses := newLatencySnapshot(w.misses, start, end)
+		s.Misses = &misses
+	}
+	if w.attempts != nil {
+		attempts := newLatencySnapshot(w.attempts, start, end)
+		s.Attempts = &attempts
+		s.Retries = maps.Clone(w.retries)
+	}
+	if w.hedges != nil {
+		hedges := *w.hedges
+		s.Hedges = &hedges
+	}
+	if w.unhedged != nil {
+		unhedged := newLatencySnapshot(w.unhedged, start, end)
+		s.Unhedged = &unhedged
+	}
+	return s
+}
+
+func newLatencySnapshot(h *hdrhistogram.Histogram, start, end time.Time) Snapshot {
+	s := Snapshot{
+		Start:    start,
+		End:      end,
+		Requests: h.TotalCount(),
+	}
+	if s.Requests == 0 {
+		return s
+	}
+
+	s.Mean = time.Duration(math.Round(h.Mean() * float64(time.Microsecond)))
+	s.Min = fromMicros(h.Min())
+	s.Max = fromMicros(h.Max())
+	s.StdDev = time.Duration(math.Round(h.StdDev() * float64(time.Microsecond)))
+	for _, p := range ReportedPercentiles {
+		s.Percentiles = append(s.Percentiles, Percentile{
+			Percentile: p,
+			Latency:    fromMicros(h.ValueAtPercentile(p)),
+		})
+	}
+	return s
+}
+
+func newBuckets(h *hdrhistogram.Histogram) []Bucket {
+	var buckets []Bucket
+	for _, bar := range h.Distribution() {
+		if bar.Count == 0 {
+			continue
+		}
+		buckets = append(buckets, Bucket{
+			From:  fromMicros(bar.From),
+			To:    fromMicros(bar.To),
+			Count: bar.Count,
+		})
+	}
+	return buckets
+}
+
+// Elapsed returns the length of the snapshot window
+func (s Snapshot) Elapsed() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// ErrorCount returns the number of failed requests
+func (s Snapshot) ErrorCount() int64 {
+	var n int64
+	for _, count := range s.Errors {
+		n += count
+	}
+	return n
+}
+
+// BudgetErrorCount returns the number of failed requests that count against
+// the error budget; keys missing from the table are reported but do not abort
+// a run
+func (s Snapshot) BudgetErrorCount() int64 {
+	return s.ErrorCount() - s.Errors[ErrorMiss]
+}
+
+// MissCount returns the number of requests for missing keys answered as expected
+func (s Snapshot) MissCount() int64 {
+	if s.Misses == nil {
+		return 0
+	}
+	return s.Misses.Requests
+}
+
+// AuthFailureCount returns the number of requests that failed authorization
+func (s Snapshot) AuthFailureCount() int64 {
+	var n int64
+	for class, count := range s.Errors {
+		if IsAuthFailure(class) {
+			n += count
+		}
+	}
+	return n
+}
+
+// TimeoutCount returns the number of requests that timed out
+func (s Snapshot) TimeoutCount() int64 {
+	return s.Errors[ErrorTimeout]
+}
+
+// RetryCount returns the number of times requests were sent again
+func (s Snapshot) RetryCount() int64 {
+	var n int64
+	for _, count := range s.Retries {
+		n += count
+	}
+	return n
+}
+
+// Successful returns the number of requests that got the expected answer
+func (s Snapshot) Successful() int64 {
+	n := s.Requests + s.MissCount()
+	if s.Unhedged != nil {
+		n += s.Unhedged.Requests
+	}
+	return n
+}
+
+// Completed returns the number of requests that finished, failed or not
+func (s Snapshot) Completed() int64 {
+	return s.Successful() + s.ErrorCount()
+}
+
+// ErrorRate returns the fraction of completed requests that failed
+func (s Snapshot) ErrorRate() float64 {
+	errors := s.ErrorCount()
+	if errors == 0 {
+		return 0
+	}
+	return float64(errors) / float64(s.Completed())
+}
+
+// FormatErrors lists the error counts by class, e.g. "http_503=2 timeout=1"
+func (s Snapshot) FormatErrors() string {
+	var parts []string
+	for _, class := range slices.Sorted(maps.Keys(s.Errors)) {
+		parts = append(parts, fmt.Sprintf("%s=%d", class, s.Errors[class]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Throughput returns the successful requests per second over the snapshot window
+func (s Snapshot) Throughput() float64 {
+	elapsed := s.Elapsed().Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Successful()) / elapsed
+}
+
+// WriteSummary prints the snapshot as the end-of-run report
+func (s Snapshot) WriteSummary(w io.Writer) {
+	fmt.Fprintf(w, "\nBenchmark Results:\n")
+	errors := s.ErrorCount()
+	fmt.Fprintf(w, "Total Requests: %d\n", s.Completed())
+	fmt.Fprintf(w, "Duration: %v\n", s.Elapsed().Round(time.Millisecond))
+	fmt.Fprintf(w, "Requests per Second: %.2f\n", s.Throughput())
+	if errors > 0 {
+		fmt.Fprintf(w, "Successful Requests: %d\n", s.Successful())
+		fmt.Fprintf(w, "Errors: %d (%.3f%%)\n", errors, s.ErrorRate()*100)
+		for _, class := range slices.Sorted(maps.Keys(s.Errors)) {
+			fmt.Fprintf(w, "  %s: %d\n", class, s.Errors[class])
+		}
+		if authFailures := s.AuthFailureCount(); authFailures > 0 {
+			fmt.Fprintf(w, "Auth Failures: %d\n", authFailures)
+		}
+		if timeouts := s.TimeoutCount(); timeouts > 0 {
+			fmt.Fprintf(w, "Timeouts: %d\n", timeouts)
+		}
+	}
+	if s.TimeoutLatencies > 0 {
+		fmt.Fprintf(w, "Latencies include %d timed out requests\n", s.TimeoutLatencies)
+	}
+	switch {
+	case s.Misses != nil:
+		fmt.Fprintf(w, "Hit Requests: %d\n", s.Requests)
+		fmt.Fprintf(w, "Miss Requests: %d\n", s.Misses.Requests)
+		s.writeLatencies(w, "Hit ")
+		s.Misses.writeLatencies(w, "Miss ")
+	case s.Unhedged != nil:
+		fmt.Fprintf(w, "Hedged Requests: %d\n", s.Requests)
+		s.writeLatencies(w, "Hedged ")
+	default:
+		s.writeLatencies(w, "")
+	}
+	if s.Hedges != nil {
+		s.Hedges.write(w)
+	}
+	if s.Unhedged != nil {
+		fmt.Fprintf(w, "Unhedged Requests: %d\n", s.Unhedged.Requests)
+		s.Unhedged.writeLatencies(w, "Unhedged ")
+	}
+	if s.Attempts != nil {
+		fmt.Fprintf(w, "Attempts: %d\n", s.Attempts.Requests)
+		fmt.Fprintf(w, "Retries: %d\n", s.RetryCount())
+		for _, class := range slices.Sorted(maps.Keys(s.Retries)) {
+			fmt.Fprintf(w, "  %s: %d\n", class, s.Retries[class])
+		}
+		s.Attempts.writeLatencies(w, "Attempt ")
+	}
+	if s.HTTP != nil {
+		s.HTTP.write(w)
+	}
+	if len(s.Endpoints) > 0 {
+		writeEndpoints(w, s.Endpoints)
+	}
+}
+
+// percentile returns the latency at one of the ReportedPercentiles
+func (s Snapshot) percentile(percentile float64) time.Duration {
+	for _, p := range s.Percentiles {
+		if p.Percentile == percentile {
+			return p.Latency
+		}
+	}
+	return 0
+}
+
+func (s Snapshot) writeLatencies(w io.Writer, label string) {
+	if s.Requests+s.TimeoutLatencies == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%sMean Latency: %v\n", label, s.Mean)
+	fmt.Fprintf(w, "%sMin Latency: %v\n", label, s.Min)
+	fmt.Fprintf(w, "%sMax Latency: %v\n", label, s.Max)
+	fmt.Fprintf(w, "%sStdDev Latency: %v\n", label, s.StdDev)
+	for _, p := range s.Percentiles {
+		fmt.Fprintf(w, "%sP%v Latency: %v\n", label, p.Percentile, p.Latency)
+	}
+}
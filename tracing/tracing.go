@@ -0,0 +1,213 @@
+package tracing
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"time"
+
+	"github.com/ParkerData/parkbench/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+	grpcstats "google.golang.org/grpc/stats"
+)
+
+// tracerName identifies the spans of the benchmark client
+const tracerName = "github.com/ParkerData/parkbench"
+
+// Setup installs the global tracer provider and W3C trace context propagation
+// described by cfg; the returned function flushes the spans left to export
+func Setup(ctx context.Context, cfg *config.Tracing) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var closeFile func() error
+	switch cfg.Exporter {
+	case config.ExporterOTLP:
+		var options []otlptracegrpc.Option
+		if cfg.Endpoint != "" {
+			options = append(options, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			options = append(options, otlptracegrpc.WithInsecure())
+		}
+		var err error
+		exporter, err = otlptracegrpc.New(ctx, options...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+	case config.ExporterFile:
+		file, err := os.Create(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to create file exporter: %w", err)
+		}
+		closeFile = file.Close
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(cfg.SampleRate)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeFile != nil {
+			if closeErr := closeFile(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+// Start begins the span of one request at its intended send time, so that
+// queueing delay in open-loop mode shows up in the trace as it does in the stats
+func Start(ctx context.Context, name string, start time.Time, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// InjectHTTP propagates the trace context of ctx in the request headers
+func InjectHTTP(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// InjectGRPC propagates the trace context of ctx in the outgoing gRPC metadata
+func InjectGRPC(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// metadataCarrier adapts gRPC metadata, whose keys are lower case, to the propagators
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// WithClientTrace adds the connection events of an HTTP request to the span in ctx
+func WithClientTrace(ctx context.Context) context.Context {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return ctx
+	}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			span.AddEvent("get_conn", trace.WithAttributes(attribute.String("host_port", hostPort)))
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			span.AddEvent("got_conn", trace.WithAttributes(
+				attribute.Bool("reused", info.Reused),
+				attribute.Bool("was_idle", info.WasIdle),
+				attribute.String("idle_time", info.IdleTime.String()),
+			))
+		},
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			span.AddEvent("dns_start", trace.WithAttributes(attribute.String("host", info.Host)))
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			span.AddEvent("dns_done", trace.WithAttributes(errorAttributes(info.Err)...))
+		},
+		ConnectStart: func(network, addr string) {
+			span.AddEvent("connect_start", trace.WithAttributes(attribute.String("addr", addr)))
+		},
+		ConnectDone: func(network, addr string, err error) {
+			span.AddEvent("connect_done", trace.WithAttributes(append(errorAttributes(err), attribute.String("addr", addr))...))
+		},
+		TLSHandshakeStart: func() {
+			span.AddEvent("tls_handshake_start")
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			span.AddEvent("tls_handshake_done", trace.WithAttributes(append(errorAttributes(err), attribute.String("tls_version", tls.VersionName(state.Version)))...))
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			span.AddEvent("wrote_request", trace.WithAttributes(errorAttributes(info.Err)...))
+		},
+		GotFirstResponseByte: func() {
+			span.AddEvent("first_byte")
+		},
+	})
+}
+
+func errorAttributes(err error) []attribute.KeyValue {
+	if err == nil {
+		return nil
+	}
+	return []attribute.KeyValue{attribute.String("error", err.Error())}
+}
+
+// StatsHandler adds the wire events of gRPC calls to the span in their context
+type StatsHandler struct{}
+
+// TagRPC implements grpc/stats.Handler
+func (StatsHandler) TagRPC(ctx context.Context, _ *grpcstats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+// HandleRPC implements grpc/stats.Handler
+func (StatsHandler) HandleRPC(ctx context.Context, s grpcstats.RPCStats) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	switch s := s.(type) {
+	case *grpcstats.OutHeader:
+		span.AddEvent("wrote_headers")
+	case *grpcstats.OutPayload:
+		span.AddEvent("wrote_request", trace.WithTimestamp(s.SentTime), trace.WithAttributes(attribute.Int("wire_length", s.WireLength)))
+	case *grpcstats.InHeader:
+		span.AddEvent("first_byte")
+	case *grpcstats.InPayload:
+		span.AddEvent("body_read", trace.WithTimestamp(s.RecvTime), trace.WithAttributes(attribute.Int("wire_length", s.WireLength)))
+	}
+}
+
+// TagConn implements grpc/stats.Handler
+func (StatsHandler) TagConn(ctx context.Context, _ *grpcstats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn implements grpc/stats.Handler
+func (StatsHandler) HandleConn(context.Context, grpcstats.ConnStats) {}
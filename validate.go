@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"github.com/ParkerData/parkbench/config"
+	parker_pb "github.com/ParkerData/parkbench/pb/parker_pb"
+	"github.com/ParkerData/parkbench/stats"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// expectedValue is the value a column of the found record must hold
+type expectedValue struct {
+	column string
+	value  *parker_pb.Value
+}
+
+// parseExpected converts the CSV value of an "expect:" column into a Value of valueType
+func parseExpected(column, valueType, raw string) (expectedValue, error) {
+	value := &parker_pb.Value{}
+	switch valueType {
+	case config.ValueBool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return expectedValue{}, fmt.Errorf("invalid bool %q for column %s: %w", raw, column, err)
+		}
+		value.Kind = &parker_pb.Value_BoolValue{BoolValue: v}
+	case config.ValueInt32:
+		v, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil {
+			return expectedValue{}, fmt.Errorf("invalid int32 %q for column %s: %w", raw, column, err)
+		}
+		value.Kind = &parker_pb.Value_Int32Value{Int32Value: int32(v)}
+	case config.ValueInt64:
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return expectedValue{}, fmt.Errorf("invalid int64 %q for column %s: %w", raw, column, err)
+		}
+		value.Kind = &parker_pb.Value_Int64Value{Int64Value: v}
+	case config.ValueFloat:
+		v, err := strconv.ParseFloat(raw, 32)
+		if err != nil {
+			return expectedValue{}, fmt.Errorf("invalid float %q for column %s: %w", raw, column, err)
+		}
+		value.Kind = &parker_pb.Value_FloatValue{FloatValue: float32(v)}
+	case config.ValueDouble:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return expectedValue{}, fmt.Errorf("invalid double %q for column %s: %w", raw, column, err)
+		}
+		value.Kind = &parker_pb.Value_DoubleValue{DoubleValue: v}
+	case config.ValueBytes:
+		v, err := hex.DecodeString(raw)
+		if err != nil {
+			return expectedValue{}, fmt.Errorf("invalid hex bytes %q for column %s: %w", raw, column, err)
+		}
+		value.Kind = &parker_pb.Value_BytesValue{BytesValue: v}
+	default:
+		value.Kind = &parker_pb.Value_StringValue{StringValue: raw}
+	}
+	return expectedValue{column: column, value: value}, nil
+}
+
+// validateRecord compares a found record with the expected values and returns
+// the error class of the first difference, or "" when they all match
+func validateRecord(record *parker_pb.RecordValue, expected []expectedValue) (string, error) {
+	for _, want := range expected {
+		got, ok := record.GetFields()[want.column]
+		if !ok {
+			return stats.ErrorMissingColumn, fmt.Errorf("column %s is missing from the record", want.column)
+		}
+		if valueType(got) != valueType(want.value) {
+			return stats.ErrorTypeMismatch, fmt.Errorf("column %s is %s, expected %s", want.column, valueType(got), valueType(want.value))
+		}
+		if !proto.Equal(got, want.value) {
+			return stats.ErrorValueMismatch, fmt.Errorf("column %s is %v, expected %v", want.column, got, want.value)
+		}
+	}
+	return "", nil
+}
+
+// validateHTTPBody decodes the JSON form of a FindResponse and validates its record
+func validateHTTPBody(body []byte, expected []expectedValue) (string, error) {
+	resp := &parker_pb.FindResponse{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, resp); err != nil {
+		return stats.ErrorInvalidResponse, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return validateRecord(resp.GetRecord(), expected)
+}
+
+// valueType names the kind of a value after the expected value types
+func valueType(value *parker_pb.Value) string {
+	switch value.GetKind().(type) {
+	case *parker_pb.Value_BoolValue:
+		return config.ValueBool
+	case *parker_pb.Value_Int32Value:
+		return config.ValueInt32
+	case *parker_pb.Value_Int64Value:
+		return config.ValueInt64
+	case *parker_pb.Value_FloatValue:
+		return config.ValueFloat
+	case *parker_pb.Value_DoubleValue:
+		return config.ValueDouble
+	case *parker_pb.Value_BytesValue:
+		return config.ValueBytes
+	case *parker_pb.Value_StringValue:
+		return config.ValueString
+	case *parker_pb.Value_ListValue:
+		return "list"
+	case *parker_pb.Value_RecordValue:
+		return "record"
+	}
+	return "null"
+}